@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/geckoboard/zendesk_dataset/cassette"
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk"
+)
+
+// recording is the cassette being recorded by the -record flag, which is
+// saved once the command has run.
+var recording *cassette.Cassette
+
+// cassetteTransport sends the requests through the cassette being recorded
+// or replayed, and is nil when there is none.
+var cassetteTransport http.RoundTripper
+
+// useCassette records the requests made to Zendesk and Geckoboard to the
+// cassette at the record path, or responds to them from the cassette at the
+// replay path without connecting to either.
+func useCassette(config *conf.Config, record, replay string) error {
+	if record != "" && replay != "" {
+		return errors.New("Only one of -record and -replay can be given")
+	}
+
+	secrets := configSecrets(config)
+
+	switch {
+	case record != "":
+		recording = cassette.New(record, secrets...)
+		cassetteTransport = recording.Transport(http.DefaultTransport)
+
+		log.Printf("INFO: Recording the requests to %s", record)
+	case replay != "":
+		c, err := cassette.Load(replay, secrets...)
+		if err != nil {
+			return err
+		}
+
+		cassetteTransport = c.Transport(http.DefaultTransport)
+		log.Printf("INFO: Replaying the %d requests recorded in %s", len(c.Interactions), replay)
+	}
+
+	return nil
+}
+
+// zendeskHTTPClient returns the HTTP client sending the Zendesk requests
+// through the cassette, or nil to use each account's own client when there
+// is no cassette.
+func zendeskHTTPClient() *http.Client {
+	if cassetteTransport == nil {
+		return nil
+	}
+
+	return &http.Client{Transport: cassetteTransport}
+}
+
+// runnerOptions returns the options for running the reports, sending the
+// Zendesk and Geckoboard requests through the cassette when there is one.
+func runnerOptions(config *conf.Config) zendesk.RunnerOptions {
+	return zendesk.RunnerOptions{
+		HTTPClient: zendeskHTTPClient(),
+		Sink:       zendesk.NewGeckoboardSink(config, cassetteTransport, log.Default()),
+	}
+}
+
+// saveRecording saves the cassette being recorded, if any.
+func saveRecording() {
+	if recording == nil {
+		return
+	}
+
+	if err := recording.Save(); err != nil {
+		log.Printf("ERRO: Saving the recorded requests failed with: %s", err.Error())
+		return
+	}
+
+	log.Printf("INFO: Saved %d requests to the cassette", len(recording.Interactions))
+}
+
+// configSecrets returns the credentials and email addresses of all the
+// accounts, which are left out of cassettes.
+func configSecrets(config *conf.Config) []string {
+	secrets := []string{config.Geckoboard.APIKey}
+	for _, g := range config.GeckoboardAccounts {
+		secrets = append(secrets, g.APIKey)
+	}
+
+	auths := []conf.Auth{config.Zendesk.Auth}
+	for _, a := range config.ZendeskAccounts {
+		auths = append(auths, a)
+	}
+
+	for _, a := range auths {
+		secrets = append(secrets, a.Email, a.Password, a.APIKey, a.OAuthToken)
+	}
+
+	return secrets
+}
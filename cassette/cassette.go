@@ -0,0 +1,262 @@
+// Package cassette records the HTTP requests made to Zendesk and Geckoboard
+// along with their responses to a file, a cassette, and replays them later
+// without making any requests, so runs can be repeated exactly in tests.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the secrets in the recorded requests and responses.
+const Redacted = "REDACTED"
+
+// Interaction is a request and the response to it. The URL is only the
+// path and query so the requests match whichever host they are sent to.
+type Interaction struct {
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	RequestBody  string            `json:"request_body,omitempty"`
+	Status       int               `json:"status"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	ResponseBody string            `json:"response_body"`
+}
+
+// recordedHeaders are the response headers kept in the cassette, the
+// clients don't use any others.
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// Cassette holds the interactions recorded or being replayed.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+
+	path      string
+	recording bool
+	secrets   []string
+
+	mu        sync.Mutex
+	used      []bool
+	unmatched []string
+}
+
+// New returns an empty cassette which records to the file at path. The
+// Authorization header is never recorded and the secrets, such as API
+// keys and email addresses, are replaced with Redacted wherever they
+// appear in the recorded URLs and bodies, as they are or URL escaped.
+func New(path string, secrets ...string) *Cassette {
+	return &Cassette{path: path, recording: true, secrets: nonEmpty(secrets)}
+}
+
+// Load reads the cassette at path to replay it. The secrets are replaced
+// in the requests before matching them as they were when recorded.
+func Load(path string, secrets ...string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("Cassette %s is not valid: %s", path, err.Error())
+	}
+
+	c.path = path
+	c.secrets = nonEmpty(secrets)
+	c.used = make([]bool, len(c.Interactions))
+
+	return &c, nil
+}
+
+// Transport returns a transport which records the requests sent through
+// next, or when replaying responds with the recorded responses instead.
+func (c *Cassette) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return transport{cassette: c, next: next}
+}
+
+// Save writes the recorded interactions to the cassette's file.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path, append(b, '\n'), 0600)
+}
+
+// Unmatched returns the requests which had no recorded response while
+// replaying, such as when a change alters the requests made.
+func (c *Cassette) Unmatched() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.unmatched...)
+}
+
+// Unused returns the recorded requests which weren't made while replaying.
+func (c *Cassette) Unused() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var unused []string
+	for i, used := range c.used {
+		if !used {
+			unused = append(unused, c.Interactions[i].Method+" "+c.Interactions[i].URL)
+		}
+	}
+
+	return unused
+}
+
+type transport struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.cassette.recording {
+		return t.record(req, body)
+	}
+
+	return t.cassette.replay(req, body)
+}
+
+func (t transport) record(req *http.Request, body string) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	c := t.cassette
+	i := Interaction{
+		Method:       req.Method,
+		URL:          c.scrub(req.URL.RequestURI()),
+		RequestBody:  c.scrub(body),
+		Status:       resp.StatusCode,
+		ResponseBody: c.scrub(string(b)),
+	}
+
+	for _, h := range recordedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			if i.Headers == nil {
+				i.Headers = map[string]string{}
+			}
+
+			i.Headers[h] = v
+		}
+	}
+
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, i)
+	c.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay responds with the first unused interaction with the same method,
+// URL and body, in the order they were recorded.
+func (c *Cassette) replay(req *http.Request, body string) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	url, body := c.scrub(req.URL.RequestURI()), c.scrub(body)
+
+	for n, i := range c.Interactions {
+		if c.used[n] || i.Method != req.Method || i.URL != url || i.RequestBody != body {
+			continue
+		}
+
+		c.used[n] = true
+
+		resp := &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+			StatusCode:    i.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{},
+			Body:          ioutil.NopCloser(strings.NewReader(i.ResponseBody)),
+			ContentLength: int64(len(i.ResponseBody)),
+			Request:       req,
+		}
+
+		for k, v := range i.Headers {
+			resp.Header.Set(k, v)
+		}
+
+		return resp, nil
+	}
+
+	c.unmatched = append(c.unmatched, req.Method+" "+url)
+	return nil, fmt.Errorf("Cassette %s has no recorded response for %s %s", c.path, req.Method, url)
+}
+
+// nonEmpty returns the secrets which aren't empty along with their query
+// and path escaped forms, such as an email address in a search, longest
+// first so those containing others are replaced whole.
+func nonEmpty(secrets []string) []string {
+	var ss []string
+	seen := map[string]bool{}
+
+	for _, s := range secrets {
+		for _, form := range []string{s, url.QueryEscape(s), url.PathEscape(s)} {
+			if form != "" && !seen[form] {
+				seen[form] = true
+				ss = append(ss, form)
+			}
+		}
+	}
+
+	sort.SliceStable(ss, func(i, j int) bool { return len(ss[i]) > len(ss[j]) })
+	return ss
+}
+
+func (c *Cassette) scrub(s string) string {
+	for _, secret := range c.secrets {
+		s = strings.Replace(s, secret, Redacted, -1)
+	}
+
+	return s
+}
+
+// readRequestBody returns the request's body, leaving it to be read again.
+func readRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return string(b), nil
+}
@@ -0,0 +1,213 @@
+package cassette
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "abc")
+		w.Header().Set("Retry-After", "2")
+
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"path":"` + r.URL.RequestURI() + `","body":"` + string(b) + `","user":"me@example.com"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.cassette.json")
+	secrets := []string{"s3cr3t", "me@example.com", ""}
+
+	rec := New(path, secrets...)
+	clt := &http.Client{Transport: rec.Transport(nil)}
+
+	send := func(clt *http.Client, method, url, body string) (*http.Response, string, error) {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+
+		resp, err := clt.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+
+		b, err := ioutil.ReadAll(resp.Body)
+		return resp, string(b), err
+	}
+
+	_, body, err := send(clt, http.MethodGet, server.URL+"/search?key=s3cr3t", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(body, "me@example.com") {
+		t.Errorf("Expected the recorded response to be returned unchanged but got %s", body)
+	}
+
+	if _, _, err := send(clt, http.MethodPut, server.URL+"/data", "records"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"s3cr3t", "me@example.com", "Authorization", "X-Request-Id", server.URL} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("Expected the cassette not to contain %s but it was\n%s", s, b)
+		}
+	}
+
+	// Replaying sends the requests elsewhere, failing if they're sent.
+	c, err := Load(path, secrets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clt = &http.Client{Transport: c.Transport(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		t.Fatal("Expected no requests to be sent when replaying")
+		return nil, nil
+	}))}
+
+	resp, body, err := send(clt, http.MethodGet, "http://zendesk.invalid/search?key=s3cr3t", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("Retry-After") != "2" {
+		t.Errorf("Expected the recorded status and headers but got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if body != `{"path":"/search?key=REDACTED","body":"","user":"REDACTED"}` {
+		t.Errorf("Expected the recorded response but got %s", body)
+	}
+
+	if _, _, err := send(clt, http.MethodPut, "http://geckoboard.invalid/data", "other records"); err == nil {
+		t.Error("Expected a request with a different body to have no recorded response")
+	}
+
+	if _, _, err := send(clt, http.MethodGet, "http://zendesk.invalid/search?key=s3cr3t", ""); err == nil {
+		t.Error("Expected each recorded response to only be replayed once")
+	}
+
+	expected := "GET /search?key=REDACTED"
+	if unmatched := c.Unmatched(); len(unmatched) != 2 || unmatched[0] != "PUT /data" || unmatched[1] != expected {
+		t.Errorf("Expected the unmatched requests PUT /data and %s but got %v", expected, unmatched)
+	}
+
+	if unused := c.Unused(); len(unused) != 1 || unused[0] != "PUT /data" {
+		t.Errorf("Expected the unused request PUT /data but got %v", unused)
+	}
+}
+
+func TestRecordScrubsEscapedSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"next_page":"` + r.URL.RequestURI() + `"}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.cassette.json")
+	secrets := []string{"me@example.com", "pass word"}
+
+	rec := New(path, secrets...)
+	clt := &http.Client{Transport: rec.Transport(nil)}
+
+	uri := "/users/pass%20word?query=requester%3Ame%40example.com&key=pass+word"
+
+	resp, err := clt.Get(server.URL + uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"me%40example.com", "pass%20word", "pass+word"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("Expected the cassette not to contain %s but it was\n%s", s, b)
+		}
+	}
+
+	// Replaying matches the request by its scrubbed URL.
+	c, err := Load(path, secrets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clt = &http.Client{Transport: c.Transport(nil)}
+	if resp, err := clt.Get("http://zendesk.invalid" + uri); err != nil {
+		t.Error(err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(invalid, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path string
+		err  string
+	}{
+		{
+			path: filepath.Join(dir, "missing.json"),
+			err:  "no such file or directory",
+		},
+		{
+			path: invalid,
+			err:  "Cassette " + invalid + " is not valid",
+		},
+	}
+
+	for i, tc := range testCases {
+		_, err := Load(tc.path)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("[spec %d] Expected error containing '%s' but got %v", i, tc.err, err)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
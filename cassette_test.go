@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+func TestConfigSecrets(t *testing.T) {
+	config := &conf.Config{
+		Geckoboard: conf.Geckoboard{APIKey: "Ap1K4y"},
+		Zendesk:    conf.Zendesk{Auth: conf.Auth{Subdomain: "testing", Email: "test@example.com", APIKey: "12345"}},
+		GeckoboardAccounts: map[string]conf.Geckoboard{
+			"marketing": {APIKey: "M4rk3t1ng"},
+		},
+		ZendeskAccounts: map[string]conf.Auth{
+			"brand": {Subdomain: "brand", OAuthToken: "0auth70k3n"},
+			"old":   {Subdomain: "old", Email: "old@example.com", Password: "p4ssw0rd"},
+		},
+	}
+
+	var secrets []string
+	for _, s := range configSecrets(config) {
+		if s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+
+	sort.Strings(secrets)
+	expected := []string{"0auth70k3n", "12345", "Ap1K4y", "M4rk3t1ng", "old@example.com", "p4ssw0rd", "test@example.com"}
+
+	if len(secrets) != len(expected) {
+		t.Fatalf("Expected secrets %v but got %v", expected, secrets)
+	}
+
+	for i := range expected {
+		if secrets[i] != expected[i] {
+			t.Errorf("Expected secrets %v but got %v", expected, secrets)
+			break
+		}
+	}
+}
+
+func TestUseCassetteRecordAndReplay(t *testing.T) {
+	err := useCassette(&conf.Config{}, "record.json", "replay.json")
+	if err == nil || err.Error() != "Only one of -record and -replay can be given" {
+		t.Errorf("Expected an error giving both -record and -replay but got %v", err)
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk"
+)
+
+// command is a subcommand of the program, run with the arguments after its name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{name: "run", summary: "Run all the reports, or only those matching the given datasets, names or tags", run: runCmd},
+	{name: "validate", summary: "Check the config and reports for problems without connecting to anything", run: validateCmd},
+	{name: "explain", summary: "Print the Zendesk search queries each report makes", run: explainCmd},
+	{name: "backfill", summary: "Run the reports in append mode as of each day in a range to give their datasets history", run: backfillCmd},
+	{name: "sync", summary: "Copy the tickets changed since the last sync to the local warehouse", run: syncCmd},
+	{name: "check", summary: "Check the Zendesk and Geckoboard credentials work", run: checkCmd},
+	{name: "datasets", summary: "List, show or delete the Geckoboard datasets", run: datasetsCmdRun},
+	{name: "templates", summary: "Print the help for the report templates as markdown", run: templatesCmd},
+	{name: "version", summary: "Print the version", run: versionCmd},
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+
+	return nil
+}
+
+// loadConfig parses the command's flags and loads the config, returning the
+// remaining arguments. The -config, -fake-zendesk, -record and -replay flags
+// can be given before or after the command name, the value after takes
+// precedence.
+func loadConfig(fs *flag.FlagSet, args []string) (*conf.Config, []string, error) {
+	path := fs.String("config", *configPath, "Path to your geckoboard zendesk configuration")
+	fake := fs.String("fake-zendesk", *fakeZendesk, "Path to a JSON file of tickets to serve from a local fake Zendesk used in place of the real one")
+	record := fs.String("record", *recordPath, "Path to a cassette file to record the Zendesk and Geckoboard requests and responses to")
+	replay := fs.String("replay", *replayPath, "Path to a cassette file to replay the recorded responses from, in place of connecting to Zendesk and Geckoboard")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	config, err := conf.LoadConfig(*path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Problem with the config: %s", err.Error())
+	}
+
+	for _, k := range config.IgnoredKeys() {
+		log.Printf("WARN: Ignoring config %s", k.String())
+	}
+
+	if *fake != "" {
+		if err := useFakeZendesk(config, *fake); err != nil {
+			return nil, nil, fmt.Errorf("Problem with the fake Zendesk: %s", err.Error())
+		}
+	}
+
+	if err := useCassette(config, *record, *replay); err != nil {
+		return nil, nil, fmt.Errorf("Problem with the cassette: %s", err.Error())
+	}
+
+	return config, fs.Args(), nil
+}
+
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	selection := addSelectionFlags(fs)
+	asOf := addAsOfFlag(fs)
+
+	config, patterns, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(config.Zendesk.Reports) == 0 {
+		return errors.New("You have no reports setup in your config under zendesk")
+	}
+
+	clock, err := asOfClock(*asOf)
+	if err != nil {
+		return err
+	}
+
+	// Check the whole config before selecting the reports so the
+	// problems are numbered as the reports appear in the file.
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if err := selection.apply(config, patterns); err != nil {
+		return err
+	}
+
+	if !checkCredentials(config) {
+		return errors.New("Fix the credentials in your config before running the reports")
+	}
+
+	opts := runnerOptions(config)
+	opts.Clock = clock
+
+	if err := zendesk.NewRunner(config, opts).Run(); err != nil {
+		return err
+	}
+
+	log.Println("Completed processing all reports...")
+
+	return nil
+}
+
+// selection holds the -only and -except flags choosing which reports to use.
+type selection struct {
+	only   *string
+	except *string
+}
+
+// addSelectionFlags adds the -only and -except flags to the command's flags,
+// defaulting to those given before the command.
+func addSelectionFlags(fs *flag.FlagSet) selection {
+	return selection{
+		only:   fs.String("only", *onlyReports, "Comma separated datasets, report names or tags of the reports to use, datasets can use * wildcards"),
+		except: fs.String("except", *exceptReports, "Comma separated datasets, report names or tags of the reports not to use"),
+	}
+}
+
+// patterns returns the -only and -except patterns, treating any extra
+// patterns as if they were given to -only.
+func (s selection) patterns(extra []string) (only, except []string) {
+	return append(splitPatterns(*s.only), extra...), splitPatterns(*s.except)
+}
+
+// apply leaves just the selected reports in the config, treating any
+// extra patterns as if they were given to -only.
+func (s selection) apply(config *conf.Config, patterns []string) error {
+	only, except := s.patterns(patterns)
+
+	if len(only) == 0 && len(except) == 0 {
+		return nil
+	}
+
+	reports, err := conf.SelectReports(config.Zendesk.Reports, only, except)
+	if err != nil {
+		return err
+	}
+
+	config.Zendesk.Reports = reports
+	if len(config.Zendesk.Reports) == 0 {
+		return errors.New("No reports match the selected datasets, names or tags")
+	}
+
+	return nil
+}
+
+// validate checks the whole config and returns the problems with it which
+// aren't with the reports left out by the selection. The problems keep the
+// numbers of their reports in the file.
+func (s selection) validate(config *conf.Config, patterns []string) error {
+	err := config.Validate()
+
+	vErr, ok := err.(conf.ValidationError)
+	if !ok {
+		return err
+	}
+
+	only, except := s.patterns(patterns)
+
+	var problems []conf.Problem
+	for _, p := range vErr.Problems {
+		if p.Report == 0 || config.Zendesk.Reports[p.Report-1].Selected(only, except) {
+			problems = append(problems, p)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return conf.ValidationError{Problems: problems}
+}
+
+// addAsOfFlag adds the -as-of flag to the command's flags, defaulting to
+// the one given before the command.
+func addAsOfFlag(fs *flag.FlagSet) *string {
+	return fs.String("as-of", *asOfDate, "Date such as 2016-01-31 to run the reports as of, in place of today")
+}
+
+// asOfClock returns a clock at the start of the -as-of date, or the
+// current time when no date was given.
+func asOfClock(date string) (zendesk.Clock, error) {
+	if date == "" {
+		return time.Now, nil
+	}
+
+	t, err := parseDateFlag("as-of", date)
+	if err != nil {
+		return nil, err
+	}
+
+	return zendesk.AsOf(t), nil
+}
+
+// parseDateFlag returns the start of the date given to the flag.
+func parseDateFlag(name, date string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("The -%s date '%s' must be a date such as 2016-01-31", name, date)
+	}
+
+	return t, nil
+}
+
+func splitPatterns(s string) []string {
+	var patterns []string
+
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+
+	return patterns
+}
+
+func validateCmd(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	selection := addSelectionFlags(fs)
+
+	config, patterns, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(config.Zendesk.Reports) == 0 {
+		return errors.New("You have no reports setup in your config under zendesk")
+	}
+
+	// Validate the whole config so the problems are numbered as the
+	// reports appear in the file, leaving out the unselected reports.
+	full := *config
+	if err := selection.apply(config, patterns); err != nil {
+		return err
+	}
+
+	if err := selection.validate(&full, patterns); err != nil {
+		return err
+	}
+
+	fmt.Printf("The config and its %d reports are valid\n", len(config.Zendesk.Reports))
+	return nil
+}
+
+func explainCmd(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	selection := addSelectionFlags(fs)
+	asOf := addAsOfFlag(fs)
+
+	config, patterns, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	clock, err := asOfClock(*asOf)
+	if err != nil {
+		return err
+	}
+
+	if err := selection.apply(config, patterns); err != nil {
+		return err
+	}
+
+	for i, r := range config.Zendesk.Reports {
+		fmt.Printf("Report %d '%s' (%s):\n", i+1, r.DataSet, r.Name)
+
+		queries, err := zendesk.ExplainReport(&r, clock())
+		if err != nil {
+			fmt.Printf("  %s\n", err.Error())
+			continue
+		}
+
+		for _, q := range queries {
+			fmt.Printf("  %s\n", q)
+		}
+	}
+
+	return nil
+}
+
+func backfillCmd(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	selection := addSelectionFlags(fs)
+	from := fs.String("from", "", "First date such as 2016-01-01 to run the reports as of")
+	to := fs.String("to", "", "Last date to run the reports as of, defaults to yesterday")
+	output := fs.String("output", "", "Path to a file to append the records to as lines of JSON, in place of sending them to Geckoboard")
+	progress := fs.String("progress", "", "Path to the file recording the days done, so an interrupted backfill carries on from there, defaults to backfill_progress.json next to the config or the output with .progress added")
+
+	config, patterns, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if *from == "" {
+		return errors.New("Give the first date to backfill with -from")
+	}
+
+	first, err := parseDateFlag("from", *from)
+	if err != nil {
+		return err
+	}
+
+	last := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	if *to != "" {
+		if last, err = parseDateFlag("to", *to); err != nil {
+			return err
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if err := selection.apply(config, patterns); err != nil {
+		return err
+	}
+
+	opts := runnerOptions(config)
+	*progress = backfillProgressPath(*progress, *output, fs.Lookup("config").Value.String())
+
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("Opening the backfill output failed with: %s", err.Error())
+		}
+		defer f.Close()
+
+		opts.Sink = zendesk.NewFileSink(f)
+	} else if !checkCredentials(config) {
+		return errors.New("Fix the credentials in your config before backfilling the reports")
+	}
+
+	return zendesk.NewRunner(config, opts).Backfill(first, last, *progress)
+}
+
+// backfillProgressPath returns the progress file given, or else the one
+// kept with the output, or next to the config when sending to Geckoboard
+// so the backfill carries on whichever directory it is run from.
+func backfillProgressPath(progress, output, configPath string) string {
+	switch {
+	case progress != "":
+		return progress
+	case output != "":
+		return output + ".progress"
+	default:
+		return filepath.Join(filepath.Dir(configPath), "backfill_progress.json")
+	}
+}
+
+func checkCmd(args []string) error {
+	config, _, err := loadConfig(flag.NewFlagSet("check", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+
+	if !checkCredentials(config) {
+		return errors.New("Not all the credentials work")
+	}
+
+	return nil
+}
+
+func syncCmd(args []string) error {
+	config, _, err := loadConfig(flag.NewFlagSet("sync", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+
+	return zendesk.SyncWarehouse(config, zendeskHTTPClient(), log.Default())
+}
+
+func datasetsCmdRun(args []string) error {
+	config, args, err := loadConfig(flag.NewFlagSet("datasets", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+
+	return newDatasetsCmd(config, os.Stdin, os.Stdout).run(args)
+}
+
+func templatesCmd(args []string) error {
+	return zendesk.WriteTemplateHelp(os.Stdout)
+}
+
+func versionCmd(args []string) error {
+	fmt.Printf("Version: %s\n", version)
+	return nil
+}
+
+// checkCredentials checks both the Zendesk and Geckoboard credentials
+// logging whether each works and returns false if either doesn't.
+func checkCredentials(config *conf.Config) bool {
+	ok := true
+
+	if err := zendesk.CheckZendesk(config, zendeskHTTPClient()); err != nil {
+		log.Printf("ERRO: Zendesk credentials failed with: %s", err.Error())
+		ok = false
+	} else {
+		log.Println("INFO: Zendesk credentials are valid")
+	}
+
+	if err := zendesk.CheckGeckoboard(config, cassetteTransport); err != nil {
+		log.Printf("ERRO: Geckoboard credentials failed with: %s", err.Error())
+		ok = false
+	} else {
+		log.Println("INFO: Geckoboard credentials are valid")
+	}
+
+	return ok
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+func TestSelectionApply(t *testing.T) {
+	reports := []conf.Report{
+		{Name: "ticket_counts", DataSet: "tickets.open", Tags: []string{"daily"}},
+		{Name: "detailed_metrics", DataSet: "tickets.reply.time"},
+		{Name: "ticket_counts_by_day", DataSet: "tickets.by.day", Tags: []string{"daily"}},
+	}
+
+	testCases := []struct {
+		args     []string
+		datasets []string
+		err      string
+	}{
+		{
+			datasets: []string{"tickets.open", "tickets.reply.time", "tickets.by.day"},
+		},
+		{
+			args:     []string{"tickets.by.day"},
+			datasets: []string{"tickets.by.day"},
+		},
+		{
+			args:     []string{"-only", "daily, detailed_metrics", "-except", "tickets.open"},
+			datasets: []string{"tickets.reply.time", "tickets.by.day"},
+		},
+		{
+			args:     []string{"-except", "tickets.*.*"},
+			datasets: []string{"tickets.open"},
+		},
+		{
+			args: []string{"tickets.closed"},
+			err:  "No report found for dataset, name or tag 'tickets.closed'",
+		},
+		{
+			args: []string{"-only", "daily", "-except", "daily"},
+			err:  "No reports match the selected datasets, names or tags",
+		},
+	}
+
+	for i, tc := range testCases {
+		fs := flag.NewFlagSet("run", flag.ContinueOnError)
+		s := addSelectionFlags(fs)
+
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+
+		config := &conf.Config{Zendesk: conf.Zendesk{Reports: reports}}
+		err := s.apply(config, fs.Args())
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+
+		if tc.err != "" {
+			continue
+		}
+
+		var datasets []string
+		for _, r := range config.Zendesk.Reports {
+			datasets = append(datasets, r.DataSet)
+		}
+
+		if !reflect.DeepEqual(datasets, tc.datasets) {
+			t.Errorf("[spec %d] Expected datasets %v but got %v", i, tc.datasets, datasets)
+		}
+	}
+}
+
+func TestAsOfClock(t *testing.T) {
+	testCases := []struct {
+		date string
+		now  time.Time
+		err  string
+	}{
+		{date: "2016-09-30", now: time.Date(2016, 9, 30, 0, 0, 0, 0, time.UTC)},
+		{date: "30/09/2016", err: "The -as-of date '30/09/2016' must be a date such as 2016-01-31"},
+	}
+
+	for i, tc := range testCases {
+		clock, err := asOfClock(tc.date)
+
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+			continue
+		}
+
+		if now := clock(); !now.Equal(tc.now) {
+			t.Errorf("[spec %d] Expected the clock to be at %s but got %s", i, tc.now, now)
+		}
+	}
+
+	// Without a date the clock is at the current time.
+	clock, _ := asOfClock("")
+	if d := time.Since(clock()); d < 0 || d > time.Minute {
+		t.Errorf("Expected the clock without a date to be at the current time but it is %s out", d)
+	}
+}
+
+func TestBackfillProgressPath(t *testing.T) {
+	testCases := []struct {
+		progress, output string
+		path             string
+	}{
+		{
+			path: filepath.Join("configs", "backfill_progress.json"),
+		},
+		{
+			output: "records.json",
+			path:   "records.json.progress",
+		},
+		{
+			progress: "progress.json",
+			output:   "records.json",
+			path:     "progress.json",
+		},
+	}
+
+	for i, tc := range testCases {
+		path := backfillProgressPath(tc.progress, tc.output, filepath.Join("configs", "zendesk.yml"))
+		if path != tc.path {
+			t.Errorf("[spec %d] Expected progress path %s but got %s", i, tc.path, path)
+		}
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	for _, name := range []string{"run", "validate", "explain", "backfill", "sync", "check", "datasets", "templates", "version"} {
+		if c := findCommand(name); c == nil || c.name != name {
+			t.Errorf("Expected to find the %s command", name)
+		}
+	}
+
+	if findCommand("bogus") != nil {
+		t.Errorf("Expected no command to be found for bogus")
+	}
+}
+
+func TestSelectionValidate(t *testing.T) {
+	config := &conf.Config{
+		Geckoboard: conf.Geckoboard{APIKey: "abc"},
+		Zendesk: conf.Zendesk{
+			Auth: conf.Auth{Subdomain: "example", Email: "test@example.com", APIKey: "12345"},
+			Reports: []conf.Report{
+				{Name: "ticket_count", DataSet: "tickets.open", Tags: []string{"daily"}},
+				{Name: "ticket_counts", DataSet: "tickets.solved", Tags: []string{"daily"}},
+				{Name: "ticket_counts_by_week", DataSet: "tickets.by.week"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		args []string
+		err  string
+	}{
+		{
+			err: "The config has 2 problems:\n" +
+				"  Report 1 'tickets.open': Report name ticket_count was not found\n" +
+				"  Report 3 'tickets.by.week': Report name ticket_counts_by_week was not found",
+		},
+		{
+			args: []string{"-only", "daily"},
+			err: "The config has 1 problems:\n" +
+				"  Report 1 'tickets.open': Report name ticket_count was not found",
+		},
+		{
+			args: []string{"-except", "tickets.open,tickets.by.week"},
+		},
+	}
+
+	for i, tc := range testCases {
+		fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+		s := addSelectionFlags(fs)
+
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+
+		err := s.validate(config, fs.Args())
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+	}
+}
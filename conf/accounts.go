@@ -0,0 +1,205 @@
+package conf
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultAccount is the name reports can use for the account configured
+// under zendesk auth or geckoboard, which is also used when they name none.
+const DefaultAccount = "default"
+
+// ZendeskAuths returns the auth of each Zendesk account the report uses,
+// which is the default account when it names none.
+func (c *Config) ZendeskAuths(r *Report) ([]Auth, error) {
+	names := r.ZendeskAccounts
+	if len(names) == 0 {
+		names = []string{DefaultAccount}
+	}
+
+	auths := make([]Auth, len(names))
+	for i, n := range names {
+		a, ok := c.zendeskAccount(n)
+		if !ok {
+			return nil, fmt.Errorf("Zendesk account '%s' was not found", n)
+		}
+
+		auths[i] = a
+	}
+
+	return auths, nil
+}
+
+// GeckoboardFor returns the Geckoboard account the report sends its data
+// to, which is the default account when it names none.
+func (c *Config) GeckoboardFor(r *Report) (*Geckoboard, error) {
+	name := r.GeckoboardAccount
+	if name == "" {
+		name = DefaultAccount
+	}
+
+	g, ok := c.geckoboardAccount(name)
+	if !ok {
+		return nil, fmt.Errorf("Geckoboard account '%s' was not found", name)
+	}
+
+	return &g, nil
+}
+
+// UsedZendeskAccounts returns the names of the Zendesk accounts used by the
+// reports, in the order they are first used.
+func (c *Config) UsedZendeskAccounts() []string {
+	var names []string
+	seen := map[string]bool{}
+
+	for _, r := range c.Zendesk.Reports {
+		accounts := r.ZendeskAccounts
+		if len(accounts) == 0 {
+			accounts = []string{DefaultAccount}
+		}
+
+		for _, n := range accounts {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+
+	return names
+}
+
+// UsedGeckoboardAccounts returns the names of the Geckoboard accounts used
+// by the reports, in the order they are first used.
+func (c *Config) UsedGeckoboardAccounts() []string {
+	var names []string
+	seen := map[string]bool{}
+
+	for _, r := range c.Zendesk.Reports {
+		n := r.GeckoboardAccount
+		if n == "" {
+			n = DefaultAccount
+		}
+
+		if !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+
+	return names
+}
+
+func (c *Config) zendeskAccount(name string) (Auth, bool) {
+	if name == DefaultAccount {
+		return c.Zendesk.Auth, true
+	}
+
+	a, ok := c.ZendeskAccounts[name]
+	return a, ok
+}
+
+func (c *Config) geckoboardAccount(name string) (Geckoboard, bool) {
+	if name == DefaultAccount {
+		return c.Geckoboard, true
+	}
+
+	g, ok := c.GeckoboardAccounts[name]
+	return g, ok
+}
+
+// validateAccounts returns the problems with the accounts, only checking
+// the default accounts when a report uses them or there are no reports.
+func (c *Config) validateAccounts() []error {
+	var errs []error
+
+	usesDefault := func(names []string) bool {
+		if len(c.Zendesk.Reports) == 0 {
+			return true
+		}
+
+		for _, n := range names {
+			if n == DefaultAccount {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if usesDefault(c.UsedGeckoboardAccounts()) {
+		errs = append(errs, c.Geckoboard.Validate()...)
+	}
+
+	if usesDefault(c.UsedZendeskAccounts()) {
+		errs = append(errs, c.Zendesk.Auth.Validate()...)
+	}
+
+	if _, ok := c.GeckoboardAccounts[DefaultAccount]; ok {
+		errs = append(errs, fmt.Errorf("Geckoboard account name '%s' is reserved for the geckoboard options", DefaultAccount))
+	}
+
+	if _, ok := c.ZendeskAccounts[DefaultAccount]; ok {
+		errs = append(errs, fmt.Errorf("Zendesk account name '%s' is reserved for the zendesk auth options", DefaultAccount))
+	}
+
+	// Check the accounts in order so the problems are always listed the same.
+	names := []string{}
+	for n := range c.GeckoboardAccounts {
+		if n != DefaultAccount {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+	for _, n := range names {
+		g := c.GeckoboardAccounts[n]
+		for _, err := range g.Validate() {
+			errs = append(errs, fmt.Errorf("Geckoboard account '%s': %s", n, err.Error()))
+		}
+	}
+
+	names = []string{}
+	for n := range c.ZendeskAccounts {
+		if n != DefaultAccount {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+	for _, n := range names {
+		a := c.ZendeskAccounts[n]
+		for _, err := range a.Validate() {
+			errs = append(errs, fmt.Errorf("Zendesk account '%s': %s", n, err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// validateReportAccounts returns the problems with the accounts the
+// report names.
+func (c *Config) validateReportAccounts(r *Report) []error {
+	var errs []error
+
+	seen := map[string]bool{}
+	for _, n := range r.ZendeskAccounts {
+		if seen[n] {
+			errs = append(errs, fmt.Errorf("Zendesk account '%s' is listed more than once", n))
+		}
+
+		seen[n] = true
+
+		if _, ok := c.zendeskAccount(n); !ok {
+			errs = append(errs, fmt.Errorf("Zendesk account '%s' was not found", n))
+		}
+	}
+
+	if r.GeckoboardAccount != "" {
+		if _, ok := c.geckoboardAccount(r.GeckoboardAccount); !ok {
+			errs = append(errs, fmt.Errorf("Geckoboard account '%s' was not found", r.GeckoboardAccount))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,107 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigAccounts(t *testing.T) {
+	c, err := parseConfig([]byte(`
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    email: test@example.com
+    api_key: '12345'
+    subdomain: main
+  reports:
+  - name: report_1
+    dataset: tickets.open
+  - name: report_1
+    dataset: tickets.open
+    zendesk_accounts: [default, brand]
+    geckoboard_account: marketing
+zendesk_accounts:
+  brand:
+    email: brand@example.com
+    password: secret
+    subdomain: brand
+geckoboard_accounts:
+  marketing:
+    api_key: def
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Errorf("Unexpected error got %s", err)
+	}
+
+	auths, err := c.ZendeskAuths(&c.Zendesk.Reports[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedAuths := []Auth{
+		{Email: "test@example.com", APIKey: "12345", Subdomain: "main"},
+		{Email: "brand@example.com", Password: "secret", Subdomain: "brand"},
+	}
+
+	if !reflect.DeepEqual(auths, expectedAuths) {
+		t.Errorf("Expected auths %+v but got %+v", expectedAuths, auths)
+	}
+
+	for i, expected := range []string{"abc", "def"} {
+		g, err := c.GeckoboardFor(&c.Zendesk.Reports[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if g.APIKey != expected {
+			t.Errorf("[spec %d] Expected geckoboard api key %s but got %s", i, expected, g.APIKey)
+		}
+	}
+
+	if used := c.UsedZendeskAccounts(); !reflect.DeepEqual(used, []string{"default", "brand"}) {
+		t.Errorf("Expected used zendesk accounts [default brand] but got %v", used)
+	}
+
+	if used := c.UsedGeckoboardAccounts(); !reflect.DeepEqual(used, []string{"default", "marketing"}) {
+		t.Errorf("Expected used geckoboard accounts [default marketing] but got %v", used)
+	}
+}
+
+func TestConfigValidateAccounts(t *testing.T) {
+	c, err := parseConfig([]byte(`
+zendesk:
+  reports:
+  - name: report_1
+    dataset: tickets.open
+    zendesk_accounts: [brand, brand, other]
+    geckoboard_account: sales
+zendesk_accounts:
+  default:
+    subdomain: main
+  brand:
+    email: brand@example.com
+    subdomain: brand
+geckoboard_accounts:
+  marketing:
+    api_key: def
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "The config has 5 problems:\n" +
+		"  Zendesk account name 'default' is reserved for the zendesk auth options\n" +
+		"  Zendesk account 'brand': Zendesk auth requires one of the api_key, password or oauth_token\n" +
+		"  Report 1 'tickets.open' (line 4): Zendesk account 'brand' is listed more than once\n" +
+		"  Report 1 'tickets.open' (line 4): Zendesk account 'other' was not found\n" +
+		"  Report 1 'tickets.open' (line 4): Geckoboard account 'sales' was not found"
+
+	if err := c.Validate(); err == nil || err.Error() != expected {
+		t.Errorf("Expected error:\n%s\nbut got:\n%v", expected, err)
+	}
+}
@@ -1,9 +1,13 @@
 package conf
 
 import (
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"time"
 
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 // Config is the toplevel instance of the config containing
@@ -11,35 +15,189 @@ import (
 type Config struct {
 	Geckoboard Geckoboard `yaml:"geckoboard"`
 	Zendesk    Zendesk    `yaml:"zendesk"`
+
+	// GeckoboardAccounts and ZendeskAccounts are named accounts reports
+	// can use in place of the geckoboard and zendesk auth options.
+	GeckoboardAccounts map[string]Geckoboard `yaml:"geckoboard_accounts"`
+	ZendeskAccounts    map[string]Auth       `yaml:"zendesk_accounts"`
+
+	// Include lists the files, which can use * wildcards, holding more
+	// filter templates and reports. Paths are relative to the config file.
+	Include []string `yaml:"include"`
+
+	// IgnoreUnknownKeys allows keys that don't match any option, such as
+	// those of newer versions, rather than failing to load the config.
+	IgnoreUnknownKeys bool `yaml:"ignore_unknown_keys"`
+
+	ignoredKeys []UnknownKey
+}
+
+// IgnoredKeys returns the unknown keys in the config which were ignored
+// because IgnoreUnknownKeys is set.
+func (c *Config) IgnoredKeys() []UnknownKey {
+	return c.ignoredKeys
 }
 
-// Geckoboard describes the authentication options.
+// Geckoboard describes the authentication and connection options.
+// MaxRetries is nil when it isn't set, so 0 turns retrying off.
 type Geckoboard struct {
-	APIKey string `yaml:"api_key"`
-	URL    string `yaml:"url"`
+	APIKey     string        `yaml:"api_key"`
+	APIKeyFile string        `yaml:"api_key_file"`
+	URL        string        `yaml:"url"`
+	Timeout    time.Duration `yaml:"timeout"`
+	MaxRetries *int          `yaml:"max_retries"`
 }
 
 // Auth makes up the Zendesk authentication options.
 type Auth struct {
-	Email     string `yaml:"email"`
-	Password  string `yaml:"password"`
-	APIKey    string `yaml:"api_key"`
-	Subdomain string `yaml:"subdomain"`
+	Email          string `yaml:"email"`
+	Password       string `yaml:"password"`
+	PasswordFile   string `yaml:"password_file"`
+	APIKey         string `yaml:"api_key"`
+	APIKeyFile     string `yaml:"api_key_file"`
+	OAuthToken     string `yaml:"oauth_token"`
+	OAuthTokenFile string `yaml:"oauth_token_file"`
+	Subdomain      string `yaml:"subdomain"`
+
+	// URL replaces https://<subdomain>.zendesk.com, such as for a host
+	// mapped domain, and Proxy is the proxy to send the requests through,
+	// which defaults to the one in the HTTPS_PROXY environment variable.
+	URL   string `yaml:"url"`
+	Proxy string `yaml:"proxy"`
+	// CAFile is a PEM file of certificates to trust as well as the system's.
+	CAFile string `yaml:"ca_file"`
+	// Timeout is the time limit for each request.
+	Timeout time.Duration `yaml:"timeout"`
 }
 
-// Zendesk contains Auth and a slice of Reports.
+// Zendesk contains Auth, the named filter templates and a slice of Reports.
 type Zendesk struct {
-	Auth    Auth     `yaml:"auth"`
-	Reports []Report `yaml:"reports"`
+	Auth      Auth                    `yaml:"auth"`
+	Cache     Cache                   `yaml:"cache"`
+	Warehouse Warehouse               `yaml:"warehouse"`
+	Filters   map[string]SearchFilter `yaml:"filters"`
+	Reports   []Report                `yaml:"reports"`
+}
+
+// Cache describes how the Zendesk search results and ticket metric sets
+// are reused by the reports of a run rather than fetched again.
+type Cache struct {
+	// TTL is how long they are reused for, which defaults to 5 minutes.
+	TTL time.Duration `yaml:"ttl"`
+	// Path is a file to keep them in between runs, they are only kept for
+	// the run without one.
+	Path string `yaml:"path"`
+	// Disabled fetches everything each time it is needed.
+	Disabled bool `yaml:"disabled"`
+}
+
+// Warehouse describes the local copy of the Zendesk tickets and their metric
+// sets, kept up to date by the sync command, which reports with the
+// warehouse source are run from.
+type Warehouse struct {
+	// Path is the file the tickets are kept in.
+	Path string `yaml:"path"`
+	// Start is the date, such as 2016-01-01, of the oldest changes to copy
+	// when first syncing. All the tickets are copied without it.
+	Start string `yaml:"start"`
 }
 
+// StartTime returns the time the first sync copies the changes from,
+// the zero time when it copies them all.
+func (w *Warehouse) StartTime() (time.Time, error) {
+	if w.Start == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(apiDateFormat, w.Start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Zendesk warehouse start '%s' must be a date such as 2016-01-01", w.Start)
+	}
+
+	return t, nil
+}
+
+// ReportSource describes where a report searches for the tickets.
+type ReportSource string
+
+const (
+	// SearchSource searches Zendesk on each run.
+	SearchSource ReportSource = "search"
+	// WarehouseSource searches the local copy of the tickets.
+	WarehouseSource ReportSource = "warehouse"
+)
+
+var validReportSources = [2]ReportSource{SearchSource, WarehouseSource}
+
+// ReportMode describes how the report data is sent to the Geckoboard dataset.
+type ReportMode string
+
+const (
+	// ReplaceMode replaces all the data in the dataset on each run.
+	ReplaceMode ReportMode = "replace"
+	// AppendMode appends the data to the dataset on each run to build up history.
+	AppendMode ReportMode = "append"
+)
+
+var validReportModes = [2]ReportMode{ReplaceMode, AppendMode}
+
 // Report describes the template to use and the filters to build for the Zendesk request.
 type Report struct {
 	Name          string       `yaml:"name"`
 	DataSet       string       `yaml:"dataset"`
+	Tags          []string     `yaml:"tags"`
+	Mode          ReportMode   `yaml:"mode"`
 	GroupBy       GroupBy      `yaml:"group_by"`
 	Filter        SearchFilter `yaml:"filter"`
 	MetricOptions MetricOption `yaml:"metric_options"`
+	Source        ReportSource `yaml:"source"`
+
+	// ZendeskAccounts names the Zendesk accounts to search, adding up the
+	// tickets from each, and GeckoboardAccount names the account to send
+	// the data to. Both use the default account when empty.
+	ZendeskAccounts   []string `yaml:"zendesk_accounts"`
+	GeckoboardAccount string   `yaml:"geckoboard_account"`
+
+	// RecreateOnSchemaChange deletes and recreates the dataset when it
+	// already exists with a different schema, losing any existing data.
+	RecreateOnSchemaChange bool `yaml:"recreate_on_schema_change"`
+
+	// file is the included file the report is from, empty for the config
+	// file, and line is where the report starts in it, 0 if unknown.
+	file string
+	line int
+}
+
+// SendMode returns the mode the report data should be sent with, defaulting
+// to ReplaceMode when none is specified, or an error if the mode is unknown.
+func (r *Report) SendMode() (ReportMode, error) {
+	if r.Mode == "" {
+		return ReplaceMode, nil
+	}
+
+	for _, m := range validReportModes {
+		if r.Mode == m {
+			return m, nil
+		}
+	}
+
+	return "", fmt.Errorf("Report mode '%s' is not valid must be one of %v", r.Mode, validReportModes)
+}
+
+// TicketSource returns where the report searches for the tickets, defaulting
+// to SearchSource when none is specified, or an error if it is unknown.
+func (r *Report) TicketSource() (ReportSource, error) {
+	if r.Source == "" {
+		return SearchSource, nil
+	}
+
+	for _, s := range validReportSources {
+		if r.Source == s {
+			return s, nil
+		}
+	}
+
+	return "", fmt.Errorf("Report source '%s' is not valid must be one of %v", r.Source, validReportSources)
 }
 
 // GroupBy describes how a report should be grouped.
@@ -60,17 +218,95 @@ func (gb *GroupBy) DisplayName() string {
 
 // LoadConfig take path and attempts to open the file and
 // returns any errors that might occur with yaml syntax or file issues.
+// Environment variables in the values are expanded and the credentials
+// are read from their files or overridden from the environment.
 func LoadConfig(path string) (*Config, error) {
-	var config Config
-
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := yaml.Unmarshal(b, &config); err != nil {
+	config, err := parseConfig(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.loadIncludes(filepath.Dir(path)); err != nil {
 		return nil, err
 	}
 
+	if err := config.applyFilterTemplates(); err != nil {
+		return nil, err
+	}
+
+	if err := config.resolveSecrets(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	config.resolvePaths(filepath.Dir(path))
+
+	return config, nil
+}
+
+func parseConfig(b []byte) (*Config, error) {
+	return parseConfigWith(b, false)
+}
+
+// parseConfigWith parses the config, allowing unknown keys when either
+// ignoreUnknownKeys or the config's own option is set.
+func parseConfigWith(b []byte, ignoreUnknownKeys bool) (*Config, error) {
+	var config Config
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 {
+		return &config, nil
+	}
+
+	if err := interpolateEnv(&doc); err != nil {
+		return nil, err
+	}
+
+	if err := doc.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	if unknown := findUnknownKeys(&doc, reflect.TypeOf(config)); len(unknown) > 0 {
+		if !config.IgnoreUnknownKeys && !ignoreUnknownKeys {
+			return nil, UnknownKeysError{Keys: unknown}
+		}
+
+		config.ignoredKeys = unknown
+	}
+
+	// Remember where each report starts so problems can point at it.
+	reports := mappingValue(mappingValue(doc.Content[0], "zendesk"), "reports")
+	if reports != nil && reports.Kind == yaml.SequenceNode {
+		for i, n := range reports.Content {
+			if i < len(config.Zendesk.Reports) {
+				config.Zendesk.Reports[i].line = n.Line
+			}
+		}
+	}
+
 	return &config, nil
 }
+
+// mappingValue returns the value node for the key in the mapping node,
+// or nil if either is missing.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+
+	return nil
+}
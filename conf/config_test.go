@@ -4,6 +4,9 @@ import (
 	"path"
 	"reflect"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 var configPath = "../fixtures"
@@ -17,7 +20,6 @@ var config = Config{
 			Email:     "test@example.com",
 			APIKey:    "12345",
 			Subdomain: "testing",
-			Password:  "test",
 		},
 		Reports: []Report{
 			{
@@ -73,6 +75,20 @@ var config = Config{
 	},
 }
 
+// configWithLines returns the expected config with the lines the reports
+// start on in the fixture.
+func configWithLines(lines ...int) Config {
+	c := config
+	c.Zendesk.Reports = make([]Report, len(config.Zendesk.Reports))
+	copy(c.Zendesk.Reports, config.Zendesk.Reports)
+
+	for i, l := range lines {
+		c.Zendesk.Reports[i].line = l
+	}
+
+	return c
+}
+
 func TestConfigLoadJSONFile(t *testing.T) {
 	// Yaml lib can also load json just check its compatibility with our stuff still works.
 	fileContents, err := LoadConfig(path.Join(configPath, "example.conf"))
@@ -81,8 +97,9 @@ func TestConfigLoadJSONFile(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !reflect.DeepEqual(*fileContents, config) {
-		t.Errorf("Expected json fileContents to be %v but got %v", config, fileContents)
+	expected := configWithLines(13, 44)
+	if !reflect.DeepEqual(*fileContents, expected) {
+		t.Errorf("Expected json fileContents to be %v but got %v", expected, fileContents)
 	}
 }
 
@@ -93,8 +110,9 @@ func TestConfigLoadYamlFile(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !reflect.DeepEqual(*fileContents, config) {
-		t.Errorf("Expected yaml fileContents to be %v but got %v", config, fileContents)
+	expected := configWithLines(11, 31)
+	if !reflect.DeepEqual(*fileContents, expected) {
+		t.Errorf("Expected yaml fileContents to be %v but got %v", expected, fileContents)
 	}
 }
 
@@ -113,3 +131,121 @@ func TestConfigNotExists(t *testing.T) {
 		t.Errorf("Expected error but didn't get one")
 	}
 }
+
+func TestReportSendMode(t *testing.T) {
+	testCases := []struct {
+		in  ReportMode
+		out ReportMode
+		err string
+	}{
+		{in: "", out: ReplaceMode},
+		{in: "replace", out: ReplaceMode},
+		{in: "append", out: AppendMode},
+		{in: "upsert", err: "Report mode 'upsert' is not valid must be one of [replace append]"},
+	}
+
+	for i, tc := range testCases {
+		r := Report{Mode: tc.in}
+		out, err := r.SendMode()
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %s but got %v", i, tc.err, err)
+		}
+
+		if out != tc.out {
+			t.Errorf("[spec %d] Expected mode %s but got %s", i, tc.out, out)
+		}
+	}
+}
+
+func TestGeckoboardConnectionOptions(t *testing.T) {
+	var g Geckoboard
+
+	err := yaml.Unmarshal([]byte("api_key: abc\ntimeout: 45s\nmax_retries: 5\n"), &g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.APIKey != "abc" || g.Timeout != 45*time.Second || g.MaxRetries == nil || *g.MaxRetries != 5 {
+		t.Errorf("Expected the api key, 45s timeout and 5 retries but got %#v", g)
+	}
+
+	// Setting no retries is kept apart from leaving them unset.
+	testCases := []struct {
+		yaml  string
+		unset bool
+	}{
+		{yaml: "max_retries: 0\n"},
+		{yaml: "api_key: abc\n", unset: true},
+	}
+
+	for i, tc := range testCases {
+		var g Geckoboard
+		if err := yaml.Unmarshal([]byte(tc.yaml), &g); err != nil {
+			t.Fatal(err)
+		}
+
+		if (g.MaxRetries == nil) != tc.unset || (!tc.unset && *g.MaxRetries != 0) {
+			t.Errorf("[spec %d] Expected max retries unset %t but got %v", i, tc.unset, g.MaxRetries)
+		}
+	}
+}
+
+func TestConfigUnknownKeys(t *testing.T) {
+	testCases := []struct {
+		yaml    string
+		err     string
+		ignored []UnknownKey
+	}{
+		{
+			yaml: "zendesk:\n  reports:\n  - name: ticket_counts\n    filter:\n      value:\n        'status:': open\n",
+		},
+		{
+			yaml: "geckoboard:\n  apikey: abc\n" +
+				"zendesk:\n  reports:\n  - name: detailed_metrics\n" +
+				"    metric_option:\n      attribute: reply_time\n" +
+				"    filter:\n      date-range:\n      - past: 7\n        units: day\n" +
+				"    colour: blue\n",
+			err: "The config has unknown keys, set ignore_unknown_keys to true to ignore them:\n" +
+				"  line 2: unknown key 'apikey', did you mean 'api_key'?\n" +
+				"  line 6: unknown key 'metric_option', did you mean 'metric_options'?\n" +
+				"  line 9: unknown key 'date-range', did you mean 'date_range'?\n" +
+				"  line 12: unknown key 'colour'",
+		},
+		{
+			yaml: "ignore_unknown_keys: true\nzendesk:\n  reports:\n  - name: ticket_counts\n    colour: blue\n",
+			ignored: []UnknownKey{
+				{Line: 5, Key: "colour"},
+			},
+		},
+		{
+			yaml: "defaults: &defaults\n  name: ticket_counts\nzendesk:\n  reports:\n  - <<: *defaults\n    dataset: tickets\n",
+			err: "The config has unknown keys, set ignore_unknown_keys to true to ignore them:\n" +
+				"  line 1: unknown key 'defaults'",
+		},
+	}
+
+	for i, tc := range testCases {
+		c, err := parseConfig([]byte(tc.yaml))
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+			continue
+		}
+
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("[spec %d] Expected error:\n%s\nbut got:\n%v", i, tc.err, err)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(c.IgnoredKeys(), tc.ignored) {
+			t.Errorf("[spec %d] Expected ignored keys %v but got %v", i, tc.ignored, c.IgnoredKeys())
+		}
+	}
+}
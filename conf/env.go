@@ -0,0 +1,226 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lookupEnv is replaced in the tests.
+var lookupEnv = os.LookupEnv
+
+// envPattern matches $$, ${NAME} and ${NAME:-default}.
+var envPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// UnsetEnvError is returned when the config refers to environment
+// variables which aren't set and have no default.
+type UnsetEnvError struct {
+	Lines []int
+	Names []string
+}
+
+func (e UnsetEnvError) Error() string {
+	var bf bytes.Buffer
+	bf.WriteString("The config uses environment variables which are not set:")
+
+	for i, n := range e.Names {
+		bf.WriteString(fmt.Sprintf("\n  line %d: %s", e.Lines[i], n))
+	}
+
+	return bf.String()
+}
+
+// interpolateEnv replaces ${NAME} in the values of the yaml node with the
+// environment variable, or the default given as ${NAME:-default} when it
+// isn't set. A literal $ is written as $$.
+func interpolateEnv(n *yaml.Node) error {
+	var unset UnsetEnvError
+	interpolateNode(n, &unset)
+
+	if len(unset.Names) > 0 {
+		return unset
+	}
+
+	return nil
+}
+
+func interpolateNode(n *yaml.Node, unset *UnsetEnvError) {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			interpolateNode(c, unset)
+		}
+	case yaml.MappingNode:
+		// Only the values, the keys are left as they are.
+		for i := 1; i < len(n.Content); i += 2 {
+			interpolateNode(n.Content[i], unset)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return
+		}
+
+		value := envPattern.ReplaceAllStringFunc(n.Value, func(m string) string {
+			if m == "$$" {
+				return "$"
+			}
+
+			sub := envPattern.FindStringSubmatch(m)
+			if v, ok := lookupEnv(sub[1]); ok {
+				return v
+			}
+
+			if sub[2] == "" {
+				unset.Lines = append(unset.Lines, n.Line)
+				unset.Names = append(unset.Names, sub[1])
+			}
+
+			return sub[3]
+		})
+
+		// Unquoted values are resolved again so ${DAYS} can be used for numbers.
+		if value != n.Value && n.Style == 0 {
+			n.Tag = ""
+		}
+
+		n.Value = value
+	}
+}
+
+// secret is a credential which can be given in the config, read from a file
+// named by the config, or overridden by an environment variable.
+type secret struct {
+	key   string
+	value *string
+	file  *string
+	env   string
+}
+
+func (c *Config) secrets() []secret {
+	return []secret{
+		{key: "geckoboard api_key", value: &c.Geckoboard.APIKey, file: &c.Geckoboard.APIKeyFile, env: "GECKOBOARD_API_KEY"},
+		{key: "zendesk auth email", value: &c.Zendesk.Auth.Email, env: "ZENDESK_EMAIL"},
+		{key: "zendesk auth subdomain", value: &c.Zendesk.Auth.Subdomain, env: "ZENDESK_SUBDOMAIN"},
+		{key: "zendesk auth api_key", value: &c.Zendesk.Auth.APIKey, file: &c.Zendesk.Auth.APIKeyFile, env: "ZENDESK_API_KEY"},
+		{key: "zendesk auth password", value: &c.Zendesk.Auth.Password, file: &c.Zendesk.Auth.PasswordFile, env: "ZENDESK_PASSWORD"},
+		{key: "zendesk auth oauth_token", value: &c.Zendesk.Auth.OAuthToken, file: &c.Zendesk.Auth.OAuthTokenFile, env: "ZENDESK_OAUTH_TOKEN"},
+	}
+}
+
+// authMethodEnvs are the environment variables for the Zendesk auth
+// methods, of which only one can be used.
+var authMethodEnvs = []string{"ZENDESK_API_KEY", "ZENDESK_PASSWORD", "ZENDESK_OAUTH_TOKEN"}
+
+func isAuthMethodEnv(name string) bool {
+	for _, env := range authMethodEnvs {
+		if name == env {
+			return true
+		}
+	}
+
+	return false
+}
+
+func envSet(name string) bool {
+	v, ok := lookupEnv(name)
+	return ok && v != ""
+}
+
+// resolveSecrets reads the secrets given as files, relative to dir, and
+// then applies any set environment variables over the config values. The
+// environment variables only apply to the default accounts.
+func (c *Config) resolveSecrets(dir string) error {
+	secrets := c.secrets()
+
+	// An auth method set in the environment replaces the one in the
+	// config rather than leaving both set.
+	fromEnv := false
+	for _, env := range authMethodEnvs {
+		fromEnv = fromEnv || envSet(env)
+	}
+
+	for _, s := range secrets {
+		if fromEnv && isAuthMethodEnv(s.env) && !envSet(s.env) {
+			*s.value, *s.file = "", ""
+		}
+	}
+
+	if err := resolveSecrets(secrets, dir); err != nil {
+		return err
+	}
+
+	for n, g := range c.GeckoboardAccounts {
+		key := fmt.Sprintf("geckoboard_accounts %s api_key", n)
+		if err := resolveSecrets([]secret{{key: key, value: &g.APIKey, file: &g.APIKeyFile}}, dir); err != nil {
+			return err
+		}
+
+		c.GeckoboardAccounts[n] = g
+	}
+
+	for n, a := range c.ZendeskAccounts {
+		key := fmt.Sprintf("zendesk_accounts %s", n)
+		err := resolveSecrets([]secret{
+			{key: key + " api_key", value: &a.APIKey, file: &a.APIKeyFile},
+			{key: key + " password", value: &a.Password, file: &a.PasswordFile},
+			{key: key + " oauth_token", value: &a.OAuthToken, file: &a.OAuthTokenFile},
+		}, dir)
+		if err != nil {
+			return err
+		}
+
+		c.ZendeskAccounts[n] = a
+	}
+
+	return nil
+}
+
+func resolveSecrets(secrets []secret, dir string) error {
+	for _, s := range secrets {
+		if s.file != nil && *s.file != "" {
+			if *s.value != "" {
+				return fmt.Errorf("Only one of %s and %s_file can be set", s.key, s.key)
+			}
+
+			b, err := ioutil.ReadFile(resolvePath(*s.file, dir))
+			if err != nil {
+				return fmt.Errorf("Reading %s_file failed with: %s", s.key, err.Error())
+			}
+
+			*s.value = strings.TrimSpace(string(b))
+		}
+
+		if s.env != "" && envSet(s.env) {
+			*s.value, _ = lookupEnv(s.env)
+		}
+	}
+
+	return nil
+}
+
+// resolvePaths makes the paths of the files the config refers to, other
+// than the secrets which are read on loading, relative to dir.
+func (c *Config) resolvePaths(dir string) {
+	c.Zendesk.Cache.Path = resolvePath(c.Zendesk.Cache.Path, dir)
+	c.Zendesk.Warehouse.Path = resolvePath(c.Zendesk.Warehouse.Path, dir)
+	c.Zendesk.Auth.CAFile = resolvePath(c.Zendesk.Auth.CAFile, dir)
+
+	for n, a := range c.ZendeskAccounts {
+		a.CAFile = resolvePath(a.CAFile, dir)
+		c.ZendeskAccounts[n] = a
+	}
+}
+
+func resolvePath(path, dir string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,189 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func stubEnv(env map[string]string) func() {
+	lookupEnv = func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	return func() { lookupEnv = os.LookupEnv }
+}
+
+func TestConfigInterpolateEnv(t *testing.T) {
+	defer stubEnv(map[string]string{
+		"GB_KEY":    "abc",
+		"SUBDOMAIN": "testing",
+		"DAYS":      "7",
+	})()
+
+	c, err := parseConfig([]byte(`
+geckoboard:
+  api_key: ${GB_KEY}
+zendesk:
+  auth:
+    subdomain: '${SUBDOMAIN}'
+    password: pa$$word
+    email: ${EMAIL:-support@example.com}
+  reports:
+  - name: ticket_counts
+    dataset: tickets.${SUBDOMAIN}
+    filter:
+      date_range:
+      - past: ${DAYS}
+        unit: day
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		got, expected interface{}
+	}{
+		{c.Geckoboard.APIKey, "abc"},
+		{c.Zendesk.Auth.Subdomain, "testing"},
+		{c.Zendesk.Auth.Password, "pa$word"},
+		{c.Zendesk.Auth.Email, "support@example.com"},
+		{c.Zendesk.Reports[0].DataSet, "tickets.testing"},
+		{c.Zendesk.Reports[0].Filter.DateRange[0].Past, 7},
+	}
+
+	for i, ch := range checks {
+		if ch.got != ch.expected {
+			t.Errorf("[spec %d] Expected %v but got %v", i, ch.expected, ch.got)
+		}
+	}
+}
+
+func TestConfigInterpolateUnsetEnv(t *testing.T) {
+	defer stubEnv(map[string]string{})()
+
+	_, err := parseConfig([]byte("geckoboard:\n  api_key: ${GB_KEY}\n  url: ${GB_URL}\n"))
+
+	expected := "The config uses environment variables which are not set:\n  line 2: GB_KEY\n  line 3: GB_URL"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error:\n%s\nbut got:\n%v", expected, err)
+	}
+}
+
+func TestConfigResolveSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zendesk_dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "zendesk_key"), []byte("12345\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		config   Config
+		env      map[string]string
+		zendesk  Auth
+		gbAPIKey string
+		err      string
+	}{
+		{
+			config:  Config{Zendesk: Zendesk{Auth: Auth{APIKeyFile: "zendesk_key"}}},
+			zendesk: Auth{APIKey: "12345", APIKeyFile: "zendesk_key"},
+		},
+		{
+			config:  Config{Zendesk: Zendesk{Auth: Auth{APIKeyFile: filepath.Join(dir, "zendesk_key")}}},
+			zendesk: Auth{APIKey: "12345", APIKeyFile: filepath.Join(dir, "zendesk_key")},
+		},
+		{
+			config: Config{
+				Geckoboard: Geckoboard{APIKey: "abc"},
+				Zendesk:    Zendesk{Auth: Auth{Email: "test@example.com", APIKeyFile: "zendesk_key"}},
+			},
+			env: map[string]string{
+				"GECKOBOARD_API_KEY": "def",
+				"ZENDESK_API_KEY":    "67890",
+				"ZENDESK_SUBDOMAIN":  "",
+				"ZENDESK_EMAIL":      "admin@example.com",
+			},
+			zendesk:  Auth{Email: "admin@example.com", APIKey: "67890", APIKeyFile: "zendesk_key"},
+			gbAPIKey: "def",
+		},
+		{
+			config:  Config{Zendesk: Zendesk{Auth: Auth{Email: "test@example.com", APIKeyFile: "zendesk_key"}}},
+			env:     map[string]string{"ZENDESK_OAUTH_TOKEN": "0auth70k3n"},
+			zendesk: Auth{Email: "test@example.com", OAuthToken: "0auth70k3n"},
+		},
+		{
+			config: Config{Zendesk: Zendesk{Auth: Auth{APIKey: "12345", APIKeyFile: "zendesk_key"}}},
+			err:    "Only one of zendesk auth api_key and zendesk auth api_key_file can be set",
+		},
+		{
+			config: Config{Zendesk: Zendesk{Auth: Auth{PasswordFile: "missing"}}},
+			err:    "Reading zendesk auth password_file failed with: open " + filepath.Join(dir, "missing") + ": no such file or directory",
+		},
+	}
+
+	for i, tc := range testCases {
+		restore := stubEnv(tc.env)
+		err := tc.config.resolveSecrets(dir)
+		restore()
+
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.config.Zendesk.Auth != tc.zendesk {
+			t.Errorf("[spec %d] Expected zendesk auth %+v but got %+v", i, tc.zendesk, tc.config.Zendesk.Auth)
+		}
+
+		if tc.config.Geckoboard.APIKey != tc.gbAPIKey {
+			t.Errorf("[spec %d] Expected geckoboard api key %q but got %q", i, tc.gbAPIKey, tc.config.Geckoboard.APIKey)
+		}
+	}
+}
+
+func TestConfigResolvePaths(t *testing.T) {
+	dir := filepath.FromSlash("/etc/zendesk_dataset")
+
+	c := Config{
+		Zendesk: Zendesk{Auth: Auth{CAFile: "ca.pem"}, Cache: Cache{Path: "cache.json"}, Warehouse: Warehouse{Path: "tickets.json"}},
+		ZendeskAccounts: map[string]Auth{
+			"brand":  {CAFile: filepath.FromSlash("/etc/ssl/brand.pem")},
+			"system": {},
+		},
+	}
+
+	c.resolvePaths(dir)
+
+	if path := filepath.Join(dir, "cache.json"); c.Zendesk.Cache.Path != path {
+		t.Errorf("Expected the cache path %q but got %q", path, c.Zendesk.Cache.Path)
+	}
+
+	if path := filepath.Join(dir, "tickets.json"); c.Zendesk.Warehouse.Path != path {
+		t.Errorf("Expected the warehouse path %q but got %q", path, c.Zendesk.Warehouse.Path)
+	}
+
+	expected := map[string]string{
+		DefaultAccount: filepath.Join(dir, "ca.pem"),
+		"brand":        filepath.FromSlash("/etc/ssl/brand.pem"),
+		"system":       "",
+	}
+
+	for name, path := range expected {
+		a, _ := c.zendeskAccount(name)
+		if a.CAFile != path {
+			t.Errorf("Expected the %s account ca_file %q but got %q", name, path, a.CAFile)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// loadIncludes adds the filter templates and reports from the included
+// files, in the order they are listed and then by name, to the config.
+func (c *Config) loadIncludes(dir string) error {
+	for _, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("Include '%s' is not a valid pattern: %s", pattern, err.Error())
+		}
+
+		// A wildcard may match nothing, such as an empty reports.d directory,
+		// but a file listed by name must exist.
+		if len(paths) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return fmt.Errorf("Included file %s does not exist", pattern)
+		}
+
+		for _, path := range paths {
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				name = path
+			}
+
+			if err := c.include(path, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) include(path, name string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	inc, err := parseConfigWith(b, c.IgnoreUnknownKeys)
+	if ukErr, ok := err.(UnknownKeysError); ok {
+		for i := range ukErr.Keys {
+			ukErr.Keys[i].File = name
+		}
+
+		return ukErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("Problem with included file %s: %s", name, err.Error())
+	}
+
+	if inc.Geckoboard != (Geckoboard{}) || inc.Zendesk.Auth != (Auth{}) || inc.Zendesk.Cache != (Cache{}) ||
+		inc.Zendesk.Warehouse != (Warehouse{}) || inc.IgnoreUnknownKeys || len(inc.Include) > 0 ||
+		len(inc.GeckoboardAccounts) > 0 || len(inc.ZendeskAccounts) > 0 {
+		return fmt.Errorf("Included file %s can only contain zendesk filters and reports", name)
+	}
+
+	for _, k := range inc.ignoredKeys {
+		k.File = name
+		c.ignoredKeys = append(c.ignoredKeys, k)
+	}
+
+	for n, f := range inc.Zendesk.Filters {
+		if _, ok := c.Zendesk.Filters[n]; ok {
+			return fmt.Errorf("Filter template '%s' in included file %s is already defined", n, name)
+		}
+
+		if c.Zendesk.Filters == nil {
+			c.Zendesk.Filters = map[string]SearchFilter{}
+		}
+
+		c.Zendesk.Filters[n] = f
+	}
+
+	for _, r := range inc.Zendesk.Reports {
+		r.file = name
+		c.Zendesk.Reports = append(c.Zendesk.Reports, r)
+	}
+
+	return nil
+}
+
+// applyFilterTemplates replaces the filter of each report which extends a
+// filter template with the template merged with the report's own filter.
+func (c *Config) applyFilterTemplates() error {
+	var problems []Problem
+
+	for i := range c.Zendesk.Reports {
+		r := &c.Zendesk.Reports[i]
+
+		f, err := c.Zendesk.resolveFilter(r.Filter, nil)
+		if err != nil {
+			problems = append(problems, Problem{Report: i + 1, DataSet: r.DataSet, File: r.file, Line: r.line, Err: err})
+			continue
+		}
+
+		r.Filter = f
+	}
+
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// resolveFilter merges the filter with the templates it extends, which
+// can themselves extend other templates.
+func (z *Zendesk) resolveFilter(f SearchFilter, seen []string) (SearchFilter, error) {
+	if f.Extends == "" {
+		return f, nil
+	}
+
+	for _, s := range seen {
+		if s == f.Extends {
+			return f, fmt.Errorf("Filter template '%s' extends itself through %s", f.Extends, strings.Join(seen, " > "))
+		}
+	}
+
+	t, ok := z.Filters[f.Extends]
+	if !ok {
+		return f, fmt.Errorf("Filter template '%s' was not found", f.Extends)
+	}
+
+	base, err := z.resolveFilter(t, append(seen, f.Extends))
+	if err != nil {
+		return f, err
+	}
+
+	return base.Merge(f), nil
+}
+
+// Merge returns a new filter with the options of o added to the filter.
+//
+// The type of o is used when it has one. Date ranges of o replace those of
+// the filter with the same attribute and the others are kept. The keys of
+// o's value and values are added to the filter's, replacing any it already
+// has, so values lists are replaced rather than added to.
+func (sf SearchFilter) Merge(o SearchFilter) SearchFilter {
+	merged := SearchFilter{
+		Extends: o.Extends,
+		Type:    sf.Type,
+	}
+
+	if o.Type != "" {
+		merged.Type = o.Type
+	}
+
+	replaced := map[dateAttribute]bool{}
+	for _, d := range o.DateRange {
+		replaced[d.attribute()] = true
+	}
+
+	for _, d := range sf.DateRange {
+		if !replaced[d.attribute()] {
+			merged.DateRange = append(merged.DateRange, d)
+		}
+	}
+
+	merged.DateRange = append(merged.DateRange, o.DateRange...)
+
+	if len(sf.Value) > 0 || len(o.Value) > 0 {
+		merged.Value = map[string]string{}
+	}
+
+	for _, m := range []map[string]string{sf.Value, o.Value} {
+		for k, v := range m {
+			merged.Value[k] = v
+		}
+	}
+
+	if len(sf.Values) > 0 || len(o.Values) > 0 {
+		merged.Values = map[string][]string{}
+	}
+
+	for _, m := range []map[string][]string{sf.Values, o.Values} {
+		for k, v := range m {
+			merged.Values[k] = append([]string(nil), v...)
+		}
+	}
+
+	return merged
+}
+
+// attribute returns the date attribute the filter applies to, which
+// defaults to created like Validate does.
+func (df DateFilter) attribute() dateAttribute {
+	if df.Attribute == "" {
+		return created
+	}
+
+	return df.Attribute
+}
@@ -0,0 +1,239 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "zendesk_dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestConfigIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.yml": `
+include:
+- reports.d/*.yml
+zendesk:
+  filters:
+    last_30_days:
+      date_range:
+      - past: 30
+        unit: day
+      values:
+        'tags:': [beta, freetrial]
+  reports:
+  - name: ticket_counts
+    dataset: tickets.open
+    filter:
+      extends: last_30_days
+      value:
+        'status:': open
+`,
+		"reports.d/b.yml": `
+zendesk:
+  reports:
+  - name: ticket_counts
+    dataset: tickets.beta
+    filter:
+      extends: beta
+`,
+		"reports.d/a.yml": `
+zendesk:
+  filters:
+    beta:
+      extends: last_30_days
+      date_range:
+      - past: 7
+        unit: day
+      values:
+        'tags:': [beta]
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	c, err := LoadConfig(filepath.Join(dir, "main.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Report{
+		{
+			Name:    "ticket_counts",
+			DataSet: "tickets.open",
+			Filter: SearchFilter{
+				Extends:   "last_30_days",
+				DateRange: DateFilters{{Past: 30, Unit: day}},
+				Value:     map[string]string{"status:": "open"},
+				Values:    map[string][]string{"tags:": {"beta", "freetrial"}},
+			},
+			line: 13,
+		},
+		{
+			Name:    "ticket_counts",
+			DataSet: "tickets.beta",
+			Filter: SearchFilter{
+				Extends:   "beta",
+				DateRange: DateFilters{{Past: 7, Unit: day}},
+				Values:    map[string][]string{"tags:": {"beta"}},
+			},
+			file: filepath.Join("reports.d", "b.yml"),
+			line: 4,
+		},
+	}
+
+	if !reflect.DeepEqual(c.Zendesk.Reports, expected) {
+		t.Errorf("Expected reports %+v but got %+v", expected, c.Zendesk.Reports)
+	}
+
+	// The templates themselves must be left as they were.
+	if len(c.Zendesk.Filters["last_30_days"].Value) != 0 {
+		t.Errorf("Expected the last_30_days template to be unchanged but got %+v", c.Zendesk.Filters["last_30_days"])
+	}
+}
+
+func TestConfigIncludeErrors(t *testing.T) {
+	testCases := []struct {
+		files map[string]string
+		err   string
+	}{
+		{
+			files: map[string]string{"main.yml": "include: [missing.yml]\n"},
+			err:   "Included file {dir}/missing.yml does not exist",
+		},
+		{
+			files: map[string]string{"main.yml": "include: [reports.d/*.yml]\n"},
+		},
+		{
+			files: map[string]string{
+				"main.yml":  "include: [other.yml]\n",
+				"other.yml": "geckoboard:\n  api_key: abc\n",
+			},
+			err: "Included file other.yml can only contain zendesk filters and reports",
+		},
+		{
+			files: map[string]string{
+				"main.yml":  "include: [other.yml]\n",
+				"other.yml": "zendesk:\n  cache:\n    path: cache.json\n",
+			},
+			err: "Included file other.yml can only contain zendesk filters and reports",
+		},
+		{
+			files: map[string]string{
+				"main.yml":  "include: [other.yml]\n",
+				"other.yml": "zendesk:\n  warehouse:\n    path: tickets.json\n",
+			},
+			err: "Included file other.yml can only contain zendesk filters and reports",
+		},
+		{
+			files: map[string]string{
+				"main.yml":  "include: [other.yml]\n",
+				"other.yml": "ignore_unknown_keys: true\nzendesk:\n  reports:\n  - name: ticket_counts\n    datset: tickets\n",
+			},
+			err: "Included file other.yml can only contain zendesk filters and reports",
+		},
+		{
+			files: map[string]string{
+				"main.yml":  "include: [other.yml]\n",
+				"other.yml": "zendesk:\n  reports:\n  - name: ticket_counts\n    datset: tickets\n",
+			},
+			err: "The config has unknown keys, set ignore_unknown_keys to true to ignore them:\n" +
+				"  other.yml line 4: unknown key 'datset', did you mean 'dataset'?",
+		},
+		{
+			files: map[string]string{
+				"main.yml":  "include: [other.yml]\nzendesk:\n  filters:\n    open: {}\n",
+				"other.yml": "zendesk:\n  filters:\n    open: {}\n",
+			},
+			err: "Filter template 'open' in included file other.yml is already defined",
+		},
+		{
+			files: map[string]string{
+				"main.yml": "zendesk:\n  filters:\n    a:\n      extends: b\n    b:\n      extends: a\n" +
+					"  reports:\n  - dataset: one\n    filter:\n      extends: a\n" +
+					"  - dataset: two\n    filter:\n      extends: c\n",
+			},
+			err: "The config has 2 problems:\n" +
+				"  Report 1 'one' (line 8): Filter template 'a' extends itself through a > b\n" +
+				"  Report 2 'two' (line 11): Filter template 'c' was not found",
+		},
+	}
+
+	for i, tc := range testCases {
+		dir := writeConfigFiles(t, tc.files)
+		_, err := LoadConfig(filepath.Join(dir, "main.yml"))
+		os.RemoveAll(dir)
+
+		expected := ""
+		if tc.err != "" {
+			expected = strings.Replace(filepath.FromSlash(tc.err), "{dir}", dir, 1)
+		}
+
+		if expected == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if expected != "" && (err == nil || err.Error() != expected) {
+			t.Errorf("[spec %d] Expected error:\n%s\nbut got:\n%v", i, expected, err)
+		}
+	}
+}
+
+func TestSearchFilterMerge(t *testing.T) {
+	template := SearchFilter{
+		Type: "ticket",
+		DateRange: DateFilters{
+			{Past: 30, Unit: day},
+			{Attribute: solved, Custom: "<2017-01-01"},
+		},
+		Value:  map[string]string{"status:": "open", "priority:": "high"},
+		Values: map[string][]string{"tags:": {"beta", "freetrial"}},
+	}
+
+	merged := template.Merge(SearchFilter{
+		Extends:   "template",
+		DateRange: DateFilters{{Attribute: created, Past: 7, Unit: day}},
+		Value:     map[string]string{"status:": "pending"},
+		Values:    map[string][]string{"tags:": {"beta"}, "group:": {"support"}},
+	})
+
+	expected := SearchFilter{
+		Extends: "template",
+		Type:    "ticket",
+		DateRange: DateFilters{
+			{Attribute: solved, Custom: "<2017-01-01"},
+			{Attribute: created, Past: 7, Unit: day},
+		},
+		Value:  map[string]string{"status:": "pending", "priority:": "high"},
+		Values: map[string][]string{"tags:": {"beta"}, "group:": {"support"}},
+	}
+
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected merged filter %+v but got %+v", expected, merged)
+	}
+
+	if template.Value["status:"] != "open" || len(template.DateRange) != 2 {
+		t.Errorf("Expected the template to be unchanged but got %+v", template)
+	}
+}
@@ -57,6 +57,8 @@ var (
 	ErrFromGreaterThanTo = errors.New("The metric group 'from' value must not be greater than the 'to' value")
 	// ErrFromEqualToTo is thrown when the group From is equal to To.
 	ErrFromEqualToTo = errors.New("The metric group 'from' value must not be equal to the 'to' value")
+	// ErrPercentageNotGrouped is thrown when the percentage is asked for by a report without groupings.
+	ErrPercentageNotGrouped = errors.New("The metric percentage is only used by the detailed_metrics report")
 )
 
 // MetricOption describes the options for metric reports
@@ -64,6 +66,9 @@ type MetricOption struct {
 	Attribute MetricAttribute `yaml:"attribute"`
 	Unit      MetricSubMetric `yaml:"unit"`
 	Grouping  []MetricGroup   `yaml:"grouping"`
+	// Percentage adds the share of the tickets in each grouping to the
+	// detailed metrics dataset.
+	Percentage bool `yaml:"percentage"`
 }
 
 // MetricGroup describes how to group ticket metrics. For instance to group
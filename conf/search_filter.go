@@ -9,16 +9,18 @@ import (
 )
 
 const (
-	missingKey      = "Missing key for value '%s'\n"
-	missingOperator = "The key '%s' is missing an operator as the last character choose one of %v\n"
-	missingValue    = "The key '%s' has no value to check against\n"
-	missingValues   = "The key '%s' has no values to check against\n"
+	missingKey      = "Missing key for value '%s'"
+	missingOperator = "The key '%s' is missing an operator as the last character choose one of %v"
+	missingValue    = "The key '%s' has no value to check against"
+	missingValues   = "The key '%s' has no values to check against"
 )
 
 var validOperators = [5]string{">", ":", "<", ">=", "<="}
 
 // SearchFilter describes a filter query on Zendesk api.
 type SearchFilter struct {
+	// Extends names the filter template this filter adds to.
+	Extends   string              `yaml:"extends"`
 	Type      string              `yaml:"type"`
 	DateRange DateFilters         `yaml:"date_range"`
 	Value     map[string]string   `yaml:"value"`
@@ -35,6 +37,51 @@ func (sf *SearchFilter) Validate() error {
 		}
 	}
 
+	// Check the keys in order so the same problem is always reported first.
+	keys := []string{}
+	for k := range sf.Value {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := validateKey(k, sf.Value[k]); err != nil {
+			return err
+		}
+
+		if sf.Value[k] == "" {
+			return fmt.Errorf(missingValue, k)
+		}
+	}
+
+	keys = []string{}
+	for k := range sf.Values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := validateKey(k, strings.Join(sf.Values[k], ", ")); err != nil {
+			return err
+		}
+
+		if len(sf.Values[k]) == 0 {
+			return fmt.Errorf(missingValues, k)
+		}
+	}
+
+	return nil
+}
+
+func validateKey(key, value string) error {
+	if key == "" {
+		return fmt.Errorf(missingKey, value)
+	}
+
+	if !keyHasOperator(key) {
+		return fmt.Errorf(missingOperator, key, validOperators)
+	}
+
 	return nil
 }
 
@@ -43,10 +90,8 @@ func (sf *SearchFilter) defaultType() {
 }
 
 func keyHasOperator(key string) bool {
-	lastChar := key[len(key)-1:]
-
 	for _, op := range validOperators {
-		if lastChar == op {
+		if strings.HasSuffix(key, op) {
 			return true
 		}
 	}
@@ -80,6 +80,50 @@ func TestValidate(t *testing.T) {
 			Valid:    false,
 			ErrorMsg: "Past is required to determine how many units to go into the past from today",
 		},
+		{
+			SF: SearchFilter{
+				Value: map[string]string{
+					"priority>=": "high",
+				},
+			},
+			Valid: true,
+		},
+		{
+			SF: SearchFilter{
+				Value: map[string]string{
+					"status": "open",
+				},
+			},
+			Valid:    false,
+			ErrorMsg: "The key 'status' is missing an operator as the last character choose one of [> : < >= <=]",
+		},
+		{
+			SF: SearchFilter{
+				Value: map[string]string{
+					"": "open",
+				},
+			},
+			Valid:    false,
+			ErrorMsg: "Missing key for value 'open'",
+		},
+		{
+			SF: SearchFilter{
+				Value: map[string]string{
+					"status:": "",
+				},
+			},
+			Valid:    false,
+			ErrorMsg: "The key 'status:' has no value to check against",
+		},
+		{
+			SF: SearchFilter{
+				Values: map[string][]string{
+					"tags:": []string{},
+				},
+			},
+			Valid:    false,
+			ErrorMsg: "The key 'tags:' has no values to check against",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,94 @@
+package conf
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Matches returns true if the pattern matches the report's dataset, which
+// can use glob wildcards such as "zendesk.tickets.*", or exactly matches
+// the report's name or one of its tags.
+func (r *Report) Matches(pattern string) bool {
+	if ok, _ := path.Match(pattern, r.DataSet); ok {
+		return true
+	}
+
+	if pattern == r.Name {
+		return true
+	}
+
+	for _, t := range r.Tags {
+		if pattern == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SelectReports returns the reports matching any of the only patterns, or
+// all the reports when there are none, leaving out those matching any of the
+// except patterns. The reports keep the order they have in the config.
+//
+// A pattern which isn't a valid glob is an error, as is an only pattern
+// which matches none of the reports, so a mistyped dataset isn't skipped
+// without notice.
+func SelectReports(reports []Report, only, except []string) ([]Report, error) {
+	for _, p := range append(append([]string{}, only...), except...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("Report pattern '%s' is not valid: %s", p, err.Error())
+		}
+	}
+
+	var unmatched []string
+	for _, p := range only {
+		if !anyMatches(reports, p) {
+			unmatched = append(unmatched, fmt.Sprintf("'%s'", p))
+		}
+	}
+
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("No report found for dataset, name or tag %s", strings.Join(unmatched, ", "))
+	}
+
+	var selected []Report
+
+	for _, r := range reports {
+		if r.Selected(only, except) {
+			selected = append(selected, r)
+		}
+	}
+
+	return selected, nil
+}
+
+// Selected returns true if the report matches any of the only patterns, or
+// there are none, and doesn't match any of the except patterns.
+func (r *Report) Selected(only, except []string) bool {
+	if len(only) > 0 && !r.matchesAny(only) {
+		return false
+	}
+
+	return !r.matchesAny(except)
+}
+
+func anyMatches(reports []Report, pattern string) bool {
+	for i := range reports {
+		if reports[i].Matches(pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Report) matchesAny(patterns []string) bool {
+	for _, p := range patterns {
+		if r.Matches(p) {
+			return true
+		}
+	}
+
+	return false
+}
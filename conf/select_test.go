@@ -0,0 +1,104 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportMatches(t *testing.T) {
+	r := Report{Name: "ticket_counts", DataSet: "zendesk.tickets.open", Tags: []string{"daily", "support"}}
+
+	testCases := []struct {
+		pattern string
+		out     bool
+	}{
+		{pattern: "zendesk.tickets.open", out: true},
+		{pattern: "zendesk.tickets.*", out: true},
+		{pattern: "zendesk.*.open", out: true},
+		{pattern: "zendesk.tickets", out: false},
+		{pattern: "ticket_counts", out: true},
+		{pattern: "ticket_counts_by_day", out: false},
+		{pattern: "daily", out: true},
+		{pattern: "weekly", out: false},
+		{pattern: "[", out: false},
+	}
+
+	for _, tc := range testCases {
+		if out := r.Matches(tc.pattern); out != tc.out {
+			t.Errorf("Expected pattern %q to match %t but got %t", tc.pattern, tc.out, out)
+		}
+	}
+}
+
+func TestSelectReports(t *testing.T) {
+	reports := []Report{
+		{Name: "ticket_counts", DataSet: "zendesk.tickets.open", Tags: []string{"daily"}},
+		{Name: "detailed_metrics", DataSet: "zendesk.reply.time", Tags: []string{"weekly"}},
+		{Name: "ticket_counts_by_day", DataSet: "zendesk.tickets.by.day", Tags: []string{"daily"}},
+	}
+
+	testCases := []struct {
+		only     []string
+		except   []string
+		datasets []string
+		err      string
+	}{
+		{
+			datasets: []string{"zendesk.tickets.open", "zendesk.reply.time", "zendesk.tickets.by.day"},
+		},
+		{
+			only:     []string{"zendesk.tickets.*"},
+			datasets: []string{"zendesk.tickets.open", "zendesk.tickets.by.day"},
+		},
+		{
+			only:     []string{"weekly", "ticket_counts"},
+			datasets: []string{"zendesk.tickets.open", "zendesk.reply.time"},
+		},
+		{
+			only:     []string{"daily"},
+			except:   []string{"zendesk.tickets.open"},
+			datasets: []string{"zendesk.tickets.by.day"},
+		},
+		{
+			except:   []string{"daily"},
+			datasets: []string{"zendesk.reply.time"},
+		},
+		{
+			except:   []string{"monthly"},
+			datasets: []string{"zendesk.tickets.open", "zendesk.reply.time", "zendesk.tickets.by.day"},
+		},
+		{
+			only: []string{"daily", "monthly", "zendesk.users.*"},
+			err:  "No report found for dataset, name or tag 'monthly', 'zendesk.users.*'",
+		},
+		{
+			only: []string{"zendesk.["},
+			err:  "Report pattern 'zendesk.[' is not valid: syntax error in pattern",
+		},
+		{
+			except: []string{"daily", "["},
+			err:    "Report pattern '[' is not valid: syntax error in pattern",
+		},
+	}
+
+	for i, tc := range testCases {
+		selected, err := SelectReports(reports, tc.only, tc.except)
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+
+		var datasets []string
+		for _, r := range selected {
+			datasets = append(datasets, r.DataSet)
+		}
+
+		if !reflect.DeepEqual(datasets, tc.datasets) {
+			t.Errorf("[spec %d] Expected datasets %v but got %v", i, tc.datasets, datasets)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownKey is a key in the config that doesn't match any option.
+type UnknownKey struct {
+	// File is the included file with the key, empty for the config file.
+	File string
+	Line int
+	Key  string
+	// Suggestion is the closest valid key, if any are close enough.
+	Suggestion string
+}
+
+func (k UnknownKey) String() string {
+	at := fmt.Sprintf("line %d", k.Line)
+	if k.File != "" {
+		at = fmt.Sprintf("%s line %d", k.File, k.Line)
+	}
+
+	if k.Suggestion == "" {
+		return fmt.Sprintf("%s: unknown key '%s'", at, k.Key)
+	}
+
+	return fmt.Sprintf("%s: unknown key '%s', did you mean '%s'?", at, k.Key, k.Suggestion)
+}
+
+// UnknownKeysError is returned when the config has keys that don't match
+// any option, which is usually a typo that would otherwise go unnoticed.
+type UnknownKeysError struct {
+	Keys []UnknownKey
+}
+
+func (e UnknownKeysError) Error() string {
+	var bf bytes.Buffer
+	bf.WriteString("The config has unknown keys, set ignore_unknown_keys to true to ignore them:")
+
+	for _, k := range e.Keys {
+		bf.WriteString("\n  ")
+		bf.WriteString(k.String())
+	}
+
+	return bf.String()
+}
+
+// findUnknownKeys walks the yaml node alongside the type it is decoded into
+// returning the mapping keys which don't match a yaml tag of the struct.
+func findUnknownKeys(n *yaml.Node, t reflect.Type) []UnknownKey {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	var keys []UnknownKey
+
+	switch {
+	case n.Kind == yaml.DocumentNode:
+		for _, c := range n.Content {
+			keys = append(keys, findUnknownKeys(c, t)...)
+		}
+	case n.Kind == yaml.SequenceNode && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		for _, c := range n.Content {
+			keys = append(keys, findUnknownKeys(c, t.Elem())...)
+		}
+	case n.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			keys = append(keys, findUnknownKeys(n.Content[i+1], t.Elem())...)
+		}
+	case n.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+
+			// Merged mappings such as <<: *defaults hold keys of the same struct.
+			if k.Value == "<<" {
+				keys = append(keys, findUnknownKeys(v, t)...)
+				continue
+			}
+
+			ft, ok := fields[k.Value]
+			if !ok {
+				keys = append(keys, UnknownKey{Line: k.Line, Key: k.Value, Suggestion: closestKey(k.Value, fields)})
+				continue
+			}
+
+			keys = append(keys, findUnknownKeys(v, ft)...)
+		}
+	}
+
+	return keys
+}
+
+// yamlFields returns the types of the struct's fields by their yaml key.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+// closestKey returns the valid key with the fewest edits from the unknown
+// key, or an empty string if none are close enough to be a likely typo.
+func closestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", len(key)/2+1
+
+	for name := range fields {
+		d := editDistance(key, name)
+		if d < bestDistance || (d == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
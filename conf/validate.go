@@ -0,0 +1,250 @@
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ReportCheck checks the options specific to a report template.
+type ReportCheck func(r *Report) error
+
+var reportChecks = map[string]ReportCheck{}
+
+// RegisterReport makes the report name known to Validate along with the
+// check for its options, which can be nil if it has none.
+func RegisterReport(name string, check ReportCheck) {
+	reportChecks[name] = check
+}
+
+// Problem is a single problem found when validating the config.
+type Problem struct {
+	// Report is the position of the report in the config starting
+	// from 1, or 0 when the problem isn't with a report.
+	Report  int
+	DataSet string
+	File    string
+	Line    int
+	Err     error
+}
+
+func (p Problem) String() string {
+	if p.Report == 0 {
+		return p.Err.Error()
+	}
+
+	var bf bytes.Buffer
+	bf.WriteString(fmt.Sprintf("Report %d '%s'", p.Report, p.DataSet))
+
+	switch {
+	case p.File != "" && p.Line > 0:
+		bf.WriteString(fmt.Sprintf(" (%s line %d)", p.File, p.Line))
+	case p.File != "":
+		bf.WriteString(fmt.Sprintf(" (%s)", p.File))
+	case p.Line > 0:
+		bf.WriteString(fmt.Sprintf(" (line %d)", p.Line))
+	}
+
+	bf.WriteString(": ")
+	bf.WriteString(p.Err.Error())
+
+	return bf.String()
+}
+
+// ValidationError holds all the problems found in the config.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e ValidationError) Error() string {
+	var bf bytes.Buffer
+	bf.WriteString(fmt.Sprintf("The config has %d problems:", len(e.Problems)))
+
+	for _, p := range e.Problems {
+		bf.WriteString("\n  ")
+		bf.WriteString(p.String())
+	}
+
+	return bf.String()
+}
+
+// Validate checks the whole config without making any requests and returns
+// a ValidationError with every problem found, or nil if there are none.
+func (c *Config) Validate() error {
+	var problems []Problem
+
+	errs := append(c.validateAccounts(), c.Zendesk.Cache.Validate()...)
+	if _, err := c.Zendesk.Warehouse.StartTime(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, err := range errs {
+		problems = append(problems, Problem{Err: err})
+	}
+
+	// Datasets only need to be unique within each Geckoboard account.
+	type dataset struct{ account, id string }
+	datasets := map[dataset]int{}
+
+	for i := range c.Zendesk.Reports {
+		r := &c.Zendesk.Reports[i]
+		errs := append(r.Validate(), c.validateReportAccounts(r)...)
+
+		if r.Source == WarehouseSource && c.Zendesk.Warehouse.Path == "" {
+			errs = append(errs, errors.New("Report uses the warehouse source but the zendesk warehouse has no path"))
+		}
+
+		ds := dataset{account: r.GeckoboardAccount, id: r.DataSet}
+		if ds.account == "" {
+			ds.account = DefaultAccount
+		}
+
+		if first, ok := datasets[ds]; ok {
+			errs = append(errs, fmt.Errorf("Dataset '%s' is already used by report %d", r.DataSet, first))
+		} else if r.DataSet != "" {
+			datasets[ds] = i + 1
+		}
+
+		for _, err := range errs {
+			problems = append(problems, Problem{Report: i + 1, DataSet: r.DataSet, File: r.file, Line: r.line, Err: err})
+		}
+	}
+
+	if len(problems) > 0 {
+		return ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// Validate returns the problems with the Geckoboard options.
+func (g *Geckoboard) Validate() []error {
+	var errs []error
+
+	if g.APIKey == "" {
+		errs = append(errs, errors.New("Geckoboard is missing the api_key"))
+	}
+
+	if g.Timeout < 0 {
+		errs = append(errs, errors.New("Geckoboard timeout must not be negative"))
+	}
+
+	if g.MaxRetries != nil && *g.MaxRetries < 0 {
+		errs = append(errs, errors.New("Geckoboard max_retries must not be negative"))
+	}
+
+	return errs
+}
+
+// Validate returns the problems with the Zendesk cache options.
+func (c *Cache) Validate() []error {
+	if c.TTL < 0 {
+		return []error{errors.New("Zendesk cache ttl must not be negative")}
+	}
+
+	return nil
+}
+
+// Validate returns the problems with the Zendesk auth options.
+func (a *Auth) Validate() []error {
+	var errs []error
+
+	if a.Subdomain == "" && a.URL == "" {
+		errs = append(errs, errors.New("Zendesk auth is missing the subdomain"))
+	}
+
+	if a.URL != "" && !validURL(a.URL, "http", "https") {
+		errs = append(errs, fmt.Errorf("Zendesk auth url '%s' must be an http or https URL", a.URL))
+	}
+
+	if a.Proxy != "" && !validURL(a.Proxy, "http", "https", "socks5") {
+		errs = append(errs, fmt.Errorf("Zendesk auth proxy '%s' must be an http, https or socks5 URL", a.Proxy))
+	}
+
+	if a.Timeout < 0 {
+		errs = append(errs, errors.New("Zendesk auth timeout must not be negative"))
+	}
+
+	var methods []string
+	for _, m := range []struct{ key, value string }{
+		{"api_key", a.APIKey},
+		{"password", a.Password},
+		{"oauth_token", a.OAuthToken},
+	} {
+		if m.value != "" {
+			methods = append(methods, m.key)
+		}
+	}
+
+	switch {
+	case len(methods) == 0:
+		errs = append(errs, errors.New("Zendesk auth requires one of the api_key, password or oauth_token"))
+	case len(methods) > 1:
+		// Older versions allowed both and quietly used the password.
+		errs = append(errs, fmt.Errorf("Zendesk auth can only use one of the api_key, password or oauth_token but "+
+			"has the %s set, remove all but the one to use (older versions used the password when it was set)",
+			strings.Join(methods, " and ")))
+	}
+
+	// OAuth tokens identify the user themselves.
+	if a.Email == "" && a.OAuthToken == "" {
+		errs = append(errs, errors.New("Zendesk auth is missing the email"))
+	}
+
+	return errs
+}
+
+// validURL returns whether s is an absolute URL with one of the schemes.
+func validURL(s string, schemes ...string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate returns the problems with the report, checking its template
+// specific options with the check registered for its name.
+func (r *Report) Validate() []error {
+	var errs []error
+
+	if r.DataSet == "" {
+		errs = append(errs, errors.New("Report is missing the dataset to send the data to"))
+	}
+
+	if _, err := r.SendMode(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := r.TicketSource(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Validate defaults the filter's type so check a copy.
+	filter := r.Filter
+	if err := filter.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	check, ok := reportChecks[r.Name]
+	if !ok {
+		return append(errs, fmt.Errorf("Report name %s was not found", r.Name))
+	}
+
+	if check != nil {
+		if err := check(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,226 @@
+package conf
+
+import (
+	"errors"
+	"testing"
+)
+
+func init() {
+	RegisterReport("report_1", nil)
+	RegisterReport("report_2", func(r *Report) error {
+		if r.MetricOptions.IsEmpty() {
+			return ErrEmptyMetricOptions
+		}
+
+		return nil
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		yaml string
+		err  string
+	}{
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    email: test@example.com
+    api_key: '12345'
+    subdomain: testing
+  reports:
+  - name: report_1
+    dataset: tickets.open
+    filter:
+      value:
+        'status:': open
+  - name: report_2
+    dataset: tickets.reply.time
+    metric_options:
+      attribute: reply_time
+      unit: business
+`,
+		},
+		{
+			yaml: `
+geckoboard:
+  timeout: -1s
+zendesk:
+  auth:
+    email: test@example.com
+  reports:
+  - name: report_1
+    dataset: tickets.open
+    mode: upsert
+    filter:
+      value:
+        status: open
+  - name: report_3
+    dataset: tickets.open
+  - name: report_2
+`,
+			err: "The config has 10 problems:\n" +
+				"  Geckoboard is missing the api_key\n" +
+				"  Geckoboard timeout must not be negative\n" +
+				"  Zendesk auth is missing the subdomain\n" +
+				"  Zendesk auth requires one of the api_key, password or oauth_token\n" +
+				"  Report 1 'tickets.open' (line 8): Report mode 'upsert' is not valid must be one of [replace append]\n" +
+				"  Report 1 'tickets.open' (line 8): The key 'status' is missing an operator as the last character choose one of [> : < >= <=]\n" +
+				"  Report 2 'tickets.open' (line 14): Report name report_3 was not found\n" +
+				"  Report 2 'tickets.open' (line 14): Dataset 'tickets.open' is already used by report 1\n" +
+				"  Report 3 '' (line 16): Report is missing the dataset to send the data to\n" +
+				"  Report 3 '' (line 16): The metric options must be present to be valid for a metric report",
+		},
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    oauth_token: 0auth70k3n
+    subdomain: testing
+`,
+		},
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    api_key: '12345'
+    password: secret
+    subdomain: testing
+`,
+			err: "The config has 2 problems:\n" +
+				"  Zendesk auth can only use one of the api_key, password or oauth_token but has the api_key and password set, " +
+				"remove all but the one to use (older versions used the password when it was set)\n" +
+				"  Zendesk auth is missing the email",
+		},
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    oauth_token: 0auth70k3n
+    url: support.example.com
+    proxy: ftp://proxy.example.com
+    timeout: -10s
+`,
+			err: "The config has 3 problems:\n" +
+				"  Zendesk auth url 'support.example.com' must be an http or https URL\n" +
+				"  Zendesk auth proxy 'ftp://proxy.example.com' must be an http, https or socks5 URL\n" +
+				"  Zendesk auth timeout must not be negative",
+		},
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    oauth_token: 0auth70k3n
+    url: http://localhost:8080
+    proxy: socks5://localhost:1080
+`,
+		},
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    oauth_token: 0auth70k3n
+    subdomain: testing
+  cache:
+    ttl: -5m
+`,
+			err: "The config has 1 problems:\n" +
+				"  Zendesk cache ttl must not be negative",
+		},
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    oauth_token: 0auth70k3n
+    subdomain: testing
+  warehouse:
+    start: last year
+  reports:
+  - name: report_1
+    dataset: tickets.open
+    source: warehouse
+  - name: report_1
+    dataset: tickets.closed
+    source: local
+`,
+			err: "The config has 3 problems:\n" +
+				"  Zendesk warehouse start 'last year' must be a date such as 2016-01-01\n" +
+				"  Report 1 'tickets.open' (line 11): Report uses the warehouse source but the zendesk warehouse has no path\n" +
+				"  Report 2 'tickets.closed' (line 14): Report source 'local' is not valid must be one of [search warehouse]",
+		},
+		{
+			yaml: `
+geckoboard:
+  api_key: abc
+zendesk:
+  auth:
+    oauth_token: 0auth70k3n
+    subdomain: testing
+  warehouse:
+    path: tickets.json
+    start: 2016-01-01
+  reports:
+  - name: report_1
+    dataset: tickets.open
+    source: warehouse
+`,
+		},
+	}
+
+	for i, tc := range testCases {
+		c, err := parseConfig([]byte(tc.yaml))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.Validate()
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error:\n%s\nbut got:\n%v", i, tc.err, err)
+		}
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	testCases := []struct {
+		problem Problem
+		out     string
+	}{
+		{
+			problem: Problem{Err: errors.New("Geckoboard is missing the api_key")},
+			out:     "Geckoboard is missing the api_key",
+		},
+		{
+			problem: Problem{Report: 2, DataSet: "tickets", Err: errors.New("Broken")},
+			out:     "Report 2 'tickets': Broken",
+		},
+		{
+			problem: Problem{Report: 2, DataSet: "tickets", Line: 7, Err: errors.New("Broken")},
+			out:     "Report 2 'tickets' (line 7): Broken",
+		},
+	}
+
+	for i, tc := range testCases {
+		if out := tc.problem.String(); out != tc.out {
+			t.Errorf("[spec %d] Expected %q but got %q", i, tc.out, out)
+		}
+	}
+}
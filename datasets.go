@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+	"github.com/geckoboard/zendesk_dataset/zendesk"
+)
+
+const datasetsUsage = `Usage: zendesk_dataset -config path datasets <command>
+
+Commands:
+  list                   List the datasets used by the reports in the config
+  show <dataset>         Show the fields of a dataset in Geckoboard
+  delete [-yes] <dataset>...
+  delete [-yes] -all     Delete the datasets from Geckoboard after confirming
+`
+
+// datasetsCmd manages the Geckoboard datasets used by the config, reading
+// confirmations from in and writing to out.
+type datasetsCmd struct {
+	config *conf.Config
+	in     *bufio.Reader
+	out    io.Writer
+}
+
+func newDatasetsCmd(config *conf.Config, in io.Reader, out io.Writer) *datasetsCmd {
+	return &datasetsCmd{
+		config: config,
+		in:     bufio.NewReader(in),
+		out:    out,
+	}
+}
+
+func (d *datasetsCmd) run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(datasetsUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return d.list()
+	case "show":
+		if len(args) != 2 {
+			return errors.New(datasetsUsage)
+		}
+
+		return d.show(args[1])
+	case "delete":
+		return d.delete(args[1:])
+	}
+
+	return fmt.Errorf("Unknown datasets command '%s'\n\n%s", args[0], datasetsUsage)
+}
+
+// list prints each dataset used by the reports in the config and
+// whether it has been created in Geckoboard yet.
+func (d *datasetsCmd) list() error {
+	w := tabwriter.NewWriter(d.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATASET\tREPORT\tSTATUS")
+
+	for _, id := range d.datasetIDs() {
+		ds := gb.DataSet{ID: id}
+		status := findStatus(ds.Find(d.client(id)))
+		if status == "" {
+			status = fmt.Sprintf("created, %d fields", len(ds.Fields))
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", id, d.reportName(id), status)
+	}
+
+	return w.Flush()
+}
+
+// show prints the fields of the dataset in Geckoboard.
+func (d *datasetsCmd) show(id string) error {
+	ds := gb.DataSet{ID: id}
+	if err := ds.Find(d.client(id)); err != nil {
+		return fmt.Errorf("Dataset '%s' %s", id, findStatus(err))
+	}
+
+	fmt.Fprintf(d.out, "Dataset:    %s\n", ds.ID)
+	fmt.Fprintf(d.out, "Created at: %s\n", ds.CreatedAt)
+	fmt.Fprintf(d.out, "Updated at: %s\n", ds.UpdatedAt)
+
+	if len(ds.UniqueBy) > 0 {
+		fmt.Fprintf(d.out, "Unique by:  %s\n", strings.Join(ds.UniqueBy, ", "))
+	}
+
+	keys := []string{}
+	for k := range ds.Fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(d.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nFIELD\tNAME\tTYPE")
+
+	for _, k := range keys {
+		f := ds.Fields[k]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", k, f.Name, fieldType(f))
+	}
+
+	return w.Flush()
+}
+
+// delete deletes the given datasets, or all those used by the config,
+// asking for confirmation of each unless -yes is given.
+func (d *datasetsCmd) delete(args []string) error {
+	fs := flag.NewFlagSet("datasets delete", flag.ContinueOnError)
+	fs.SetOutput(d.out)
+	all := fs.Bool("all", false, "Delete all the datasets used by the reports in the config")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ids := fs.Args()
+	if *all {
+		ids = d.datasetIDs()
+	}
+
+	if len(ids) == 0 {
+		return errors.New(datasetsUsage)
+	}
+
+	for _, id := range ids {
+		if !*yes && !d.confirm(fmt.Sprintf("Delete dataset '%s' and all its data? [y/N]: ", id)) {
+			fmt.Fprintf(d.out, "Skipped dataset '%s'\n", id)
+			continue
+		}
+
+		if err := (gb.DataSet{ID: id}).Delete(d.client(id)); err != nil {
+			return fmt.Errorf("Deleting dataset '%s' failed with: %s", id, err.Error())
+		}
+
+		fmt.Fprintf(d.out, "Deleted dataset '%s'\n", id)
+	}
+
+	return nil
+}
+
+func (d *datasetsCmd) confirm(question string) bool {
+	fmt.Fprint(d.out, question)
+
+	answer, _ := d.in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// datasetIDs returns the datasets used by the reports in the order
+// they appear in the config without duplicates.
+func (d *datasetsCmd) datasetIDs() []string {
+	var ids []string
+	seen := map[string]bool{}
+
+	for _, r := range d.config.Zendesk.Reports {
+		if r.DataSet == "" || seen[r.DataSet] {
+			continue
+		}
+
+		seen[r.DataSet] = true
+		ids = append(ids, r.DataSet)
+	}
+
+	return ids
+}
+
+// client returns a client for the Geckoboard account of the report using
+// the dataset, or the default account if no report uses it.
+func (d *datasetsCmd) client(id string) *gb.Client {
+	for _, r := range d.config.Zendesk.Reports {
+		if r.DataSet != id {
+			continue
+		}
+
+		if account, err := d.config.GeckoboardFor(&r); err == nil {
+			return zendesk.NewGeckoboardClient(account, cassetteTransport)
+		}
+	}
+
+	return zendesk.NewGeckoboardClient(&d.config.Geckoboard, cassetteTransport)
+}
+
+func (d *datasetsCmd) reportName(id string) string {
+	for _, r := range d.config.Zendesk.Reports {
+		if r.DataSet == id {
+			return r.Name
+		}
+	}
+
+	return ""
+}
+
+// findStatus describes why finding a dataset failed, or returns an empty
+// string if it didn't.
+func findStatus(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if gbe, ok := err.(gb.Error); ok && gbe.StatusCode == http.StatusNotFound {
+		return "not created yet"
+	}
+
+	return fmt.Sprintf("couldn't be found: %s", err.Error())
+}
+
+func fieldType(f gb.Field) string {
+	t := f.Type
+
+	switch {
+	case f.CurrencyCode != "":
+		t = fmt.Sprintf("%s (%s)", t, f.CurrencyCode)
+	case f.TimeUnit != "":
+		t = fmt.Sprintf("%s (%s)", t, f.TimeUnit)
+	}
+
+	if f.Optional {
+		t += ", optional"
+	}
+
+	return t
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+func buildDatasetsCmd(serverURL, input string, out *bytes.Buffer) *datasetsCmd {
+	config := &conf.Config{
+		Geckoboard: conf.Geckoboard{URL: serverURL},
+		Zendesk: conf.Zendesk{
+			Reports: []conf.Report{
+				{Name: "ticket_counts", DataSet: "tickets.open"},
+				{Name: "detailed_metrics", DataSet: "tickets.reply.time"},
+				{Name: "ticket_counts_by_day", DataSet: "tickets.open"},
+			},
+		},
+	}
+
+	return newDatasetsCmd(config, strings.NewReader(input), out)
+}
+
+func TestDatasetsList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/datasets/tickets.open" {
+			fmt.Fprint(w, `{"id":"tickets.open","fields":{"count":{"name":"Count","type":"number"}}}`)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"message":"Dataset not found"}}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	if err := buildDatasetsCmd(server.URL, "", &out).run([]string{"list"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "DATASET             REPORT            STATUS\n" +
+		"tickets.open        ticket_counts     created, 1 fields\n" +
+		"tickets.reply.time  detailed_metrics  not created yet\n"
+
+	if out.String() != expected {
+		t.Errorf("Expected output:\n%s\nbut got:\n%s", expected, out.String())
+	}
+}
+
+func TestDatasetsShow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"tickets.open","unique_by":["date"],"fields":{`+
+			`"date":{"name":"Date","type":"date"},`+
+			`"average":{"name":"Average","type":"duration","time_unit":"minutes","optional":true}}}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	if err := buildDatasetsCmd(server.URL, "", &out).run([]string{"show", "tickets.open"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"Dataset:    tickets.open",
+		"Unique by:  date",
+		"average  Average  duration (minutes), optional",
+		"date     Date     date",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected output to contain %q but got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestDatasetsDelete(t *testing.T) {
+	testCases := []struct {
+		args    []string
+		input   string
+		deleted []string
+	}{
+		{
+			args:    []string{"delete", "tickets.open"},
+			input:   "y\n",
+			deleted: []string{"/datasets/tickets.open"},
+		},
+		{
+			args:  []string{"delete", "tickets.open"},
+			input: "\n",
+		},
+		{
+			args:    []string{"delete", "-all"},
+			input:   "n\nyes\n",
+			deleted: []string{"/datasets/tickets.reply.time"},
+		},
+		{
+			args:    []string{"delete", "-yes", "-all"},
+			deleted: []string{"/datasets/tickets.open", "/datasets/tickets.reply.time"},
+		},
+	}
+
+	for i, tc := range testCases {
+		var deleted []string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "DELETE" {
+				t.Errorf("[spec %d] Expected DELETE request, got %q", i, r.Method)
+			}
+
+			deleted = append(deleted, r.URL.Path)
+			fmt.Fprint(w, "{}")
+		}))
+
+		var out bytes.Buffer
+		err := buildDatasetsCmd(server.URL, tc.input, &out).run(tc.args)
+		server.Close()
+
+		if err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if strings.Join(deleted, ",") != strings.Join(tc.deleted, ",") {
+			t.Errorf("[spec %d] Expected %v to be deleted but got %v", i, tc.deleted, deleted)
+		}
+	}
+}
+
+func TestDatasetsUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"show"}, {"delete"}, {"rename"}} {
+		var out bytes.Buffer
+
+		err := buildDatasetsCmd("", "", &out).run(args)
+		if err == nil || !strings.Contains(err.Error(), "Usage:") {
+			t.Errorf("Expected usage error for %v but got %v", args, err)
+		}
+	}
+}
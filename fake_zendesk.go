@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk/zendesktest"
+)
+
+// fakeZendeskToken is used by accounts without any credentials, which the
+// fake Zendesk needs some of but doesn't check.
+const fakeZendeskToken = "fake-zendesk"
+
+// useFakeZendesk starts a fake Zendesk on a local port serving the tickets
+// in the file and points every Zendesk account in the config at it. The
+// tickets are moved so the newest was created now, keeping them in reports
+// which search relative to today.
+func useFakeZendesk(config *conf.Config, path string) error {
+	tickets, err := zendesktest.ReadTickets(path)
+	if err != nil {
+		return err
+	}
+
+	zendesktest.ShiftDates(tickets, time.Now())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("Starting the fake Zendesk failed with: %s", err.Error())
+	}
+
+	go http.Serve(l, zendesktest.New(tickets))
+
+	url := "http://" + l.Addr().String()
+	useZendeskURL(config, url)
+
+	log.Printf("INFO: Using a fake Zendesk at %s serving %d tickets from %s", url, len(tickets), path)
+	return nil
+}
+
+// useZendeskURL sends the requests of every Zendesk account to the url,
+// directly rather than through any proxy.
+func useZendeskURL(config *conf.Config, url string) {
+	fake := func(a conf.Auth) conf.Auth {
+		a.URL, a.Proxy, a.CAFile = url, "", ""
+
+		if a.APIKey == "" && a.Password == "" && a.OAuthToken == "" {
+			a.OAuthToken = fakeZendeskToken
+		}
+
+		return a
+	}
+
+	config.Zendesk.Auth = fake(config.Zendesk.Auth)
+
+	for n, a := range config.ZendeskAccounts {
+		config.ZendeskAccounts[n] = fake(a)
+	}
+}
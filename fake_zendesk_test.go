@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+func TestUseZendeskURL(t *testing.T) {
+	config := &conf.Config{
+		Zendesk: conf.Zendesk{Auth: conf.Auth{Subdomain: "testing", Proxy: "http://proxy.example.com"}},
+		ZendeskAccounts: map[string]conf.Auth{
+			"brand": {Subdomain: "brand", Email: "test@example.com", APIKey: "12345", CAFile: "ca.pem"},
+		},
+	}
+
+	useZendeskURL(config, "http://127.0.0.1:8080")
+
+	expected := map[string]conf.Auth{
+		conf.DefaultAccount: {Subdomain: "testing", OAuthToken: fakeZendeskToken, URL: "http://127.0.0.1:8080"},
+		"brand":             {Subdomain: "brand", Email: "test@example.com", APIKey: "12345", URL: "http://127.0.0.1:8080"},
+	}
+
+	for name, auth := range expected {
+		auths, err := config.ZendeskAuths(&conf.Report{ZendeskAccounts: []string{name}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if auths[0] != auth {
+			t.Errorf("Expected the %s account %+v but got %+v", name, auth, auths[0])
+		}
+	}
+}
+
+func TestUseFakeZendesk(t *testing.T) {
+	config := &conf.Config{}
+
+	if err := useFakeZendesk(config, filepath.Join("fixtures", "zendesk_tickets.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", config.Zendesk.Auth.URL+"/api/v2/search.json?query=type%3Aticket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+config.Zendesk.Auth.OAuthToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the fake Zendesk to respond with status 200 but got %d: %s", resp.StatusCode, b)
+	}
+
+	if err := useFakeZendesk(config, "missing.json"); err == nil {
+		t.Error("Expected an error for a missing tickets file but got none")
+	}
+}
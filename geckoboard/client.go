@@ -5,8 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// sleep is used to wait between retries and is replaced in tests.
+var sleep = time.Sleep
+
 type Client struct {
 	config Config
 	client *http.Client
@@ -16,25 +21,42 @@ func New(config Config) *Client {
 	cfg := defaultConfig()
 	cfg.mergeIn(config)
 
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout, Transport: cfg.Transport}
+	}
+
 	return &Client{
-		client: http.DefaultClient,
+		client: client,
 		config: cfg,
 	}
 }
 
+// sendNewRequest builds and sends the request, retrying with backoff when
+// the request fails to send, is rate limited or Geckoboard has a server error.
+// Appends are only retried when rate limited, see shouldRetry.
 func (c Client) sendNewRequest(method, path string, body interface{}) (*http.Response, error) {
-	req, err := c.newRequest(method, path, body)
-	if err != nil {
-		return nil, err
-	}
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(method, path, body)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.sendRequest(req)
+		if attempt >= c.config.MaxRetries || !shouldRetry(method, resp, err) {
+			return resp, err
+		}
 
-	return c.sendRequest(req)
+		sleep(c.retryWait(resp, attempt))
+	}
 }
 
 func (c Client) newRequest(method, path string, body interface{}) (*http.Request, error) {
 	var buf bytes.Buffer
 	if body != nil {
-		json.NewEncoder(&buf).Encode(body)
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return nil, err
+		}
 	}
 
 	url := fmt.Sprintf("%s%s", c.config.URL, path)
@@ -48,6 +70,9 @@ func (c Client) newRequest(method, path string, body interface{}) (*http.Request
 	return req, nil
 }
 
+// sendRequest sends the request returning the response for the caller to
+// read and close when successful. Otherwise the body is decoded into an Error
+// holding the status code and closed.
 func (c Client) sendRequest(req *http.Request) (*http.Response, error) {
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -58,8 +83,53 @@ func (c Client) sendRequest(req *http.Request) (*http.Response, error) {
 	case http.StatusCreated, http.StatusOK:
 		return resp, nil
 	default:
-		var gbe Error
+		defer resp.Body.Close()
+
+		gbe := Error{StatusCode: resp.StatusCode}
 		json.NewDecoder(resp.Body).Decode(&gbe)
 		return resp, gbe
 	}
 }
+
+// shouldRetry reports whether the request is worth sending again. Rate
+// limited requests weren't applied so are always retried, otherwise only the
+// requests which are safe to repeat are, as an append which errored or timed
+// out may have added its records.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if method == "POST" {
+		return false
+	}
+
+	if resp == nil {
+		return err != nil
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// retryWait returns how long to wait before the next attempt, using the
+// Retry-After header when rate limited otherwise doubling the wait each attempt.
+func (c Client) retryWait(resp *http.Response, attempt int) time.Duration {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+
+	return c.config.RetryWait << uint(attempt)
+}
+
+// Ping checks the API key is accepted by Geckoboard, returning an Error with
+// the 401 status code when it isn't.
+func (c Client) Ping() error {
+	resp, err := c.sendNewRequest("GET", "/", nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSendRequest(t *testing.T) {
@@ -39,3 +41,166 @@ func TestSendRequest(t *testing.T) {
 		t.Fatalf("Expected %d status code, got %d", http.StatusOK, resp.StatusCode)
 	}
 }
+
+func TestSendRequestRetries(t *testing.T) {
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	testCases := []struct {
+		method        string
+		maxRetries    int
+		statuses      []int
+		retryAfter    string
+		expectedWaits []time.Duration
+		err           string
+	}{
+		{
+			statuses:      []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			expectedWaits: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			statuses:      []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:    "7",
+			expectedWaits: []time.Duration{7 * time.Second},
+		},
+		{
+			statuses:      []int{503, 503, 503, 503, 503},
+			expectedWaits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+			err:           "Service Unavailable (status 503)",
+		},
+		{
+			statuses: []int{http.StatusBadRequest},
+			err:      "Bad Request (status 400)",
+		},
+		{
+			maxRetries: NoRetries,
+			statuses:   []int{503},
+			err:        "Service Unavailable (status 503)",
+		},
+		{
+			method:   "POST",
+			statuses: []int{503},
+			err:      "Service Unavailable (status 503)",
+		},
+		{
+			method:        "POST",
+			statuses:      []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:    "7",
+			expectedWaits: []time.Duration{7 * time.Second},
+		},
+	}
+
+	for i, tc := range testCases {
+		waits = nil
+		requests := 0
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.retryAfter != "" {
+				w.Header().Set("Retry-After", tc.retryAfter)
+			}
+
+			w.WriteHeader(tc.statuses[requests])
+			requests++
+		}))
+
+		method := tc.method
+		if method == "" {
+			method = "PUT"
+		}
+
+		client := New(Config{URL: s.URL, MaxRetries: tc.maxRetries})
+		_, err := client.sendNewRequest(method, "/foobar", map[string]string{"foo": "bar"})
+		s.Close()
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+
+		if !reflect.DeepEqual(waits, tc.expectedWaits) {
+			t.Errorf("[spec %d] Expected waits %v but got %v", i, tc.expectedWaits, waits)
+		}
+	}
+}
+
+func TestSendRequestErrorStatusCode(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Your API key is invalid"}}`))
+	}))
+	defer s.Close()
+
+	client := New(Config{URL: s.URL})
+	_, err := client.sendNewRequest("GET", "/", nil)
+
+	gbe, ok := err.(Error)
+	if !ok {
+		t.Fatalf("Expected an Error, got %#v", err)
+	}
+
+	if gbe.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, gbe.StatusCode)
+	}
+
+	if gbe.Error() != "Your API key is invalid (status 401)" {
+		t.Errorf("Unexpected error message %q", gbe.Error())
+	}
+}
+
+func TestNewRequestEncodingError(t *testing.T) {
+	client := New(Config{URL: "http://localhost"})
+
+	_, err := client.newRequest("PUT", "/foobar", map[string]interface{}{"bad": make(chan int)})
+	if err == nil {
+		t.Fatal("Expected an encoding error")
+	}
+}
+
+func TestNewUsesConfiguredHTTPClient(t *testing.T) {
+	hc := &http.Client{}
+
+	if c := New(Config{HTTPClient: hc}); c.client != hc {
+		t.Errorf("Expected the configured http client to be used")
+	}
+
+	if c := New(Config{Timeout: 5 * time.Second}); c.client.Timeout != 5*time.Second {
+		t.Errorf("Expected the http client timeout to be 5s, got %s", c.client.Timeout)
+	}
+
+	rt := &http.Transport{}
+	if c := New(Config{Transport: rt}); c.client.Transport != rt {
+		t.Errorf("Expected the configured transport to be used")
+	}
+}
+
+func TestPing(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/" {
+			t.Fatalf("Expected GET request to /, got %s %s", r.Method, r.URL.Path)
+		}
+
+		user, _, _ := r.BasicAuth()
+		if user != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"Your API key is invalid"}}`))
+			return
+		}
+
+		w.Write([]byte("{}"))
+	}))
+	defer s.Close()
+
+	if err := New(Config{URL: s.URL, Key: "secret"}).Ping(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	err := New(Config{URL: s.URL, Key: "wrong"}).Ping()
+	if err == nil || err.Error() != "Your API key is invalid (status 401)" {
+		t.Errorf("Expected invalid API key error, got %v", err)
+	}
+}
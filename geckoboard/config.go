@@ -1,13 +1,57 @@
 package geckoboard
 
+import (
+	"net/http"
+	"time"
+)
+
+// Geckoboard's documented limits on the number of records that can be sent in
+// a single request and that a single dataset can hold.
+const (
+	defaultBatchSize   = 500
+	defaultRecordLimit = 5000
+)
+
+// NoRetries is the MaxRetries which turns off retrying, as leaving it 0
+// uses the default.
+const NoRetries = -1
+
 type Config struct {
 	Key string
 	URL string
+
+	// BatchSize is the most records sent in a single request, larger record
+	// sets are split into multiple requests.
+	BatchSize int
+	// RecordLimit is the most records a dataset can hold.
+	RecordLimit int
+
+	// HTTPClient is used to send the requests, when nil a client with the
+	// Timeout and Transport is used.
+	HTTPClient *http.Client
+	// Transport sends the requests when there is no HTTPClient, defaulting
+	// to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Timeout is the time limit for each request.
+	Timeout time.Duration
+	// MaxRetries is how many times a request is retried when it fails to
+	// send, is rate limited or Geckoboard has a server error. Appends are
+	// only retried when rate limited as they may have been applied. Set it
+	// to NoRetries to not retry.
+	MaxRetries int
+	// RetryWait is the wait before the first retry, doubling for each
+	// retry after it. Rate limited requests wait as long as Geckoboard asks.
+	RetryWait time.Duration
 }
 
 func defaultConfig() Config {
 	return Config{
-		URL: "https://api.geckoboard.com",
+		URL:         "https://api.geckoboard.com",
+		BatchSize:   defaultBatchSize,
+		RecordLimit: defaultRecordLimit,
+		Timeout:     30 * time.Second,
+		MaxRetries:  3,
+		RetryWait:   time.Second,
 	}
 }
 
@@ -19,4 +63,32 @@ func (config *Config) mergeIn(other Config) {
 	if other.URL != "" {
 		config.URL = other.URL
 	}
+
+	if other.BatchSize > 0 {
+		config.BatchSize = other.BatchSize
+	}
+
+	if other.RecordLimit > 0 {
+		config.RecordLimit = other.RecordLimit
+	}
+
+	if other.HTTPClient != nil {
+		config.HTTPClient = other.HTTPClient
+	}
+
+	if other.Transport != nil {
+		config.Transport = other.Transport
+	}
+
+	if other.Timeout > 0 {
+		config.Timeout = other.Timeout
+	}
+
+	if other.MaxRetries != 0 {
+		config.MaxRetries = other.MaxRetries
+	}
+
+	if other.RetryWait > 0 {
+		config.RetryWait = other.RetryWait
+	}
 }
@@ -11,3 +11,16 @@ func TestMergeInConfig(t *testing.T) {
 		t.Fatalf("Api Key is missing")
 	}
 }
+
+func TestDefaultConfigLimits(t *testing.T) {
+	c := defaultConfig()
+	c.mergeIn(Config{BatchSize: 10})
+
+	if c.BatchSize != 10 {
+		t.Errorf("Expected batch size to be 10, got %d", c.BatchSize)
+	}
+
+	if c.RecordLimit != defaultRecordLimit {
+		t.Errorf("Expected record limit to default to %d, got %d", defaultRecordLimit, c.RecordLimit)
+	}
+}
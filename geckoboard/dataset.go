@@ -3,65 +3,176 @@ package geckoboard
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"reflect"
 	"time"
 )
 
 const (
-	NumberFieldType   = "number"
-	DateFieldType     = "date"
-	DatetimeFieldType = "datetime"
-	StringFieldType   = "string"
+	NumberFieldType     = "number"
+	DateFieldType       = "date"
+	DatetimeFieldType   = "datetime"
+	StringFieldType     = "string"
+	PercentageFieldType = "percentage"
+	MoneyFieldType      = "money"
+	DurationFieldType   = "duration"
+)
+
+// The time units supported by duration fields.
+const (
+	Milliseconds = "milliseconds"
+	Seconds      = "seconds"
+	Minutes      = "minutes"
+	Hours        = "hours"
 )
 
 type DataSet struct {
 	ID        string    `json:"id,omitempty"`
 	Fields    Fields    `json:"fields"`
+	UniqueBy  []string  `json:"unique_by,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Fields map[string]Field
 
+// Field describes a dataset field. Money fields require the CurrencyCode as
+// an ISO 4217 code and duration fields the TimeUnit of their values. Optional
+// fields may be null or left out of records.
 type Field struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Optional     bool   `json:"optional,omitempty"`
+	CurrencyCode string `json:"currency_code,omitempty"`
+	TimeUnit     string `json:"time_unit,omitempty"`
 }
 
 type Record map[string]interface{}
 
 func (s DataSet) Delete(c *Client) error {
-	resp, err := c.sendNewRequest("DELETE", fmt.Sprintf("/datasets/%s", s.ID), nil)
+	var body struct{}
+	return s.request(c, "DELETE", "", nil, &body)
+}
+
+// SendAll replaces all the data in the dataset with the records. When there
+// are more records than fit in a single request the first batch replaces the
+// data and the remaining batches are appended. Records beyond the dataset's
+// record limit are trimmed from the start, so they should be ordered oldest first.
+func (s DataSet) SendAll(c *Client, recs interface{}) error {
+	records, err := recordSlice(recs)
 	if err != nil {
 		return err
 	}
 
-	var body struct{}
-	return json.NewDecoder(resp.Body).Decode(&body)
-}
+	if records.Len() > c.config.RecordLimit {
+		records = records.Slice(records.Len()-c.config.RecordLimit, records.Len())
+	}
+
+	batches := batchRecords(records, c.config.BatchSize)
 
-func (s DataSet) SendAll(c *Client, recs interface{}) error {
 	data := struct {
 		Data interface{} `json:"data"`
-	}{Data: recs}
+	}{Data: batches[0]}
 
-	resp, err := c.sendNewRequest("PUT", fmt.Sprintf("/datasets/%s/data", s.ID), data)
-	if err != nil {
+	var body struct{}
+	if err := s.request(c, "PUT", "/data", data, &body); err != nil {
 		return err
 	}
 
-	var body struct{}
-	return json.NewDecoder(resp.Body).Decode(&body)
+	return s.appendBatches(c, batches[1:], "")
 }
 
-func (s *DataSet) FindOrCreate(c *Client) error {
-	resp, err := c.sendNewRequest("PUT", fmt.Sprintf("/datasets/%s", s.ID), s)
+// Append adds the records to the dataset rather than replacing them. Records
+// matching existing ones on the dataset's unique_by fields are updated, and
+// when deleteBy names a date or datetime field the oldest records are removed
+// once the dataset exceeds Geckoboard's record limit. Without deleteBy sending
+// more records than the limit returns a RecordLimitError.
+func (s DataSet) Append(c *Client, recs interface{}, deleteBy string) error {
+	records, err := recordSlice(recs)
 	if err != nil {
 		return err
 	}
 
+	if records.Len() > c.config.RecordLimit {
+		if deleteBy == "" {
+			return RecordLimitError{DataSetID: s.ID, Count: records.Len(), Limit: c.config.RecordLimit}
+		}
+
+		records = records.Slice(records.Len()-c.config.RecordLimit, records.Len())
+	}
+
+	return s.appendBatches(c, batchRecords(records, c.config.BatchSize), deleteBy)
+}
+
+func (s DataSet) appendBatches(c *Client, batches []interface{}, deleteBy string) error {
+	for _, batch := range batches {
+		data := struct {
+			Data     interface{} `json:"data"`
+			DeleteBy string      `json:"delete_by,omitempty"`
+		}{Data: batch, DeleteBy: deleteBy}
+
+		var body struct{}
+		if err := s.request(c, "POST", "/data", data, &body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordSlice returns the records as a reflect.Value so any slice of
+// records can be split into batches.
+func recordSlice(recs interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(recs)
+	if v.Kind() != reflect.Slice {
+		return v, fmt.Errorf("Records must be a slice but got %T", recs)
+	}
+
+	return v, nil
+}
+
+// batchRecords splits the records into batches of at most size records,
+// always returning at least one batch so an empty slice is still sent.
+func batchRecords(records reflect.Value, size int) []interface{} {
+	if records.Len() == 0 {
+		return []interface{}{records.Interface()}
+	}
+
+	var batches []interface{}
+	for i := 0; i < records.Len(); i += size {
+		end := i + size
+		if end > records.Len() {
+			end = records.Len()
+		}
+
+		batches = append(batches, records.Slice(i, end).Interface())
+	}
+
+	return batches
+}
+
+// Find loads the schema of the existing dataset with the same ID.
+func (s *DataSet) Find(c *Client) error {
 	var s2 DataSet
-	err = json.NewDecoder(resp.Body).Decode(&s2)
-	if err != nil {
+	if err := s.request(c, "GET", "", nil, &s2); err != nil {
+		return err
+	}
+
+	mergeDataSets(s, &s2)
+
+	return nil
+}
+
+// FindOrCreate creates the dataset or finds the existing one with the same
+// schema. When Geckoboard responds that the dataset exists with a different
+// schema a SchemaMismatchError describing what differs is returned.
+func (s *DataSet) FindOrCreate(c *Client) error {
+	var s2 DataSet
+	if err := s.request(c, "PUT", "", s, &s2); err != nil {
+		if gbe, ok := err.(Error); ok && gbe.StatusCode == http.StatusConflict {
+			return s.schemaMismatch(c, err)
+		}
+
 		return err
 	}
 
@@ -70,8 +181,49 @@ func (s *DataSet) FindOrCreate(c *Client) error {
 	return nil
 }
 
+// schemaMismatch finds the existing dataset after Geckoboard responded to
+// creating it with the conflict error and returns a SchemaMismatchError
+// describing what differs. The conflict error is returned when nothing
+// differs, and the error finding the dataset when that fails.
+func (s *DataSet) schemaMismatch(c *Client, conflict error) error {
+	existing := DataSet{ID: s.ID}
+	if err := existing.Find(c); err != nil {
+		return fmt.Errorf("Finding the schema of dataset '%s' after it conflicted failed with: %s", s.ID, err.Error())
+	}
+
+	mErr := SchemaMismatchError{DataSetID: s.ID, Diffs: CompareFields(s.Fields, existing.Fields)}
+	if !SameUniqueBy(s.UniqueBy, existing.UniqueBy) {
+		mErr.UniqueBy = &UniqueByDiff{Expected: s.UniqueBy, Actual: existing.UniqueBy}
+	}
+
+	if len(mErr.Diffs) == 0 && mErr.UniqueBy == nil {
+		return conflict
+	}
+
+	return mErr
+}
+
+// request sends a request to the dataset's path followed by the subPath and
+// decodes the response into out. Geckoboard errors are given the dataset ID.
+func (s DataSet) request(c *Client, method, subPath string, body, out interface{}) error {
+	resp, err := c.sendNewRequest(method, fmt.Sprintf("/datasets/%s%s", s.ID, subPath), body)
+	if err != nil {
+		if gbe, ok := err.(Error); ok {
+			gbe.DataSetID = s.ID
+			return gbe
+		}
+
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 func mergeDataSets(dOut, dIn *DataSet) {
 	dOut.Fields = dIn.Fields
+	dOut.UniqueBy = dIn.UniqueBy
 	dOut.CreatedAt = dIn.CreatedAt
 	dOut.UpdatedAt = dIn.UpdatedAt
 }
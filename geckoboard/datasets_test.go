@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -63,7 +64,7 @@ func TestDatasetsCreateError(t *testing.T) {
 
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Error{InnerError{"FoobarError"}})
+		json.NewEncoder(w).Encode(Error{InnerError: InnerError{"FoobarError"}})
 	}))
 
 	c := New(Config{URL: s.URL})
@@ -73,8 +74,9 @@ func TestDatasetsCreateError(t *testing.T) {
 		t.Fatalf("Expected error to occur")
 	}
 
-	if err.Error() != "FoobarError" {
-		t.Fatalf("Expected error message to equal FoobarError, got %q", err.Error())
+	expected := "FoobarError (dataset 'foobar', status 400)"
+	if err.Error() != expected {
+		t.Fatalf("Expected error message to equal %q, got %q", expected, err.Error())
 	}
 }
 
@@ -111,7 +113,7 @@ func TestDatasetsDeleteError(t *testing.T) {
 
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Error{InnerError{"FoobarError"}})
+		json.NewEncoder(w).Encode(Error{InnerError: InnerError{"FoobarError"}})
 	}))
 
 	c := New(Config{URL: s.URL})
@@ -121,8 +123,9 @@ func TestDatasetsDeleteError(t *testing.T) {
 		t.Fatalf("Expected error to occur")
 	}
 
-	if err.Error() != "FoobarError" {
-		t.Fatalf("Expected error message to equal FoobarError, got %q", err.Error())
+	expected := "FoobarError (dataset 'foobar', status 400)"
+	if err.Error() != expected {
+		t.Fatalf("Expected error message to equal %q, got %q", expected, err.Error())
 	}
 }
 
@@ -181,7 +184,7 @@ func TestDatasetsSendAllError(t *testing.T) {
 
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Error{InnerError{"FoobarError"}})
+		json.NewEncoder(w).Encode(Error{InnerError: InnerError{"FoobarError"}})
 	}))
 
 	c := New(Config{URL: s.URL})
@@ -191,7 +194,457 @@ func TestDatasetsSendAllError(t *testing.T) {
 		t.Fatalf("Expected error to occur")
 	}
 
-	if err.Error() != "FoobarError" {
-		t.Fatalf("Expected error message to equal FoobarError, got %q", err.Error())
+	expected := "FoobarError (dataset 'foobar', status 400)"
+	if err.Error() != expected {
+		t.Fatalf("Expected error message to equal %q, got %q", expected, err.Error())
+	}
+}
+
+func TestDatasetsAppendData(t *testing.T) {
+	recs := []Record{
+		{"date": "2016-06-01", "count": 1},
+		{"date": "2016-06-02", "count": 3},
+	}
+
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Fatalf("Expected POST request, got %q", r.Method)
+		}
+
+		if r.URL.Path != "/datasets/foobar/data" {
+			t.Fatalf(`Expected path to be "/datasets/foobar/data", got %q`, r.URL.Path)
+		}
+
+		var body struct {
+			Data     []Record `json:"data"`
+			DeleteBy string   `json:"delete_by"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if len(body.Data) != 2 {
+			t.Fatalf("Expected 2 records, got %d", len(body.Data))
+		}
+
+		if body.DeleteBy != "date" {
+			t.Fatalf(`Expected delete_by to be "date", got %q`, body.DeleteBy)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct{}{})
+	}))
+
+	c := New(Config{URL: s.URL})
+
+	err := d.Append(c, recs, "date")
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+}
+
+func TestDatasetsAppendWithoutDeleteBy(t *testing.T) {
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if _, ok := body["delete_by"]; ok {
+			t.Fatalf("Expected delete_by to be omitted, got %v", body["delete_by"])
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct{}{})
+	}))
+
+	c := New(Config{URL: s.URL})
+
+	err := d.Append(c, []Record{{"count": 1}}, "")
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+}
+
+func TestDatasetsAppendError(t *testing.T) {
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Error{InnerError: InnerError{"FoobarError"}})
+	}))
+
+	c := New(Config{URL: s.URL})
+
+	err := d.Append(c, []Record{}, "")
+	if err == nil {
+		t.Fatalf("Expected error to occur")
+	}
+
+	expected := "FoobarError (dataset 'foobar', status 400)"
+	if err.Error() != expected {
+		t.Fatalf("Expected error message to equal %q, got %q", expected, err.Error())
+	}
+}
+
+func TestDatasetsCreateWithUniqueBy(t *testing.T) {
+	d := DataSet{
+		ID:       "foobar",
+		Fields:   Fields{"date": Field{Type: DateFieldType, Name: "Date"}},
+		UniqueBy: []string{"date"},
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body DataSet
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if len(body.UniqueBy) != 1 || body.UniqueBy[0] != "date" {
+			t.Fatalf(`Expected unique_by to be ["date"], got %v`, body.UniqueBy)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	}))
+
+	c := New(Config{URL: s.URL})
+
+	err := d.FindOrCreate(c)
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+
+	if len(d.UniqueBy) != 1 {
+		t.Fatalf("Expected unique_by to be kept, got %v", d.UniqueBy)
+	}
+}
+
+func TestDatasetsSendAllBatches(t *testing.T) {
+	var recs []Record
+	for i := 0; i < 7; i++ {
+		recs = append(recs, Record{"count": i})
+	}
+
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	var requests []string
+	var counts []int
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Data []Record `json:"data"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&body)
+
+		requests = append(requests, r.Method)
+		counts = append(counts, len(body.Data))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct{}{})
+	}))
+
+	c := New(Config{URL: s.URL, BatchSize: 3, RecordLimit: 100})
+
+	err := d.SendAll(c, recs)
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+
+	if !reflect.DeepEqual(requests, []string{"PUT", "POST", "POST"}) {
+		t.Errorf("Expected a PUT followed by two POST requests, got %v", requests)
+	}
+
+	if !reflect.DeepEqual(counts, []int{3, 3, 1}) {
+		t.Errorf("Expected batches of [3 3 1] records, got %v", counts)
+	}
+}
+
+func TestDatasetsSendAllTrimsToRecordLimit(t *testing.T) {
+	recs := []Record{{"count": 1}, {"count": 2}, {"count": 3}, {"count": 4}}
+
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	var sent []Record
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Data []Record `json:"data"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.Data...)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct{}{})
+	}))
+
+	c := New(Config{URL: s.URL, RecordLimit: 2})
+
+	err := d.SendAll(c, recs)
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 records to be sent, got %d", len(sent))
+	}
+
+	if sent[0]["count"] != float64(3) || sent[1]["count"] != float64(4) {
+		t.Errorf("Expected the oldest records to be trimmed, got %v", sent)
+	}
+}
+
+func TestDatasetsSendAllInvalidRecords(t *testing.T) {
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	c := New(Config{URL: "http://localhost"})
+
+	err := d.SendAll(c, Record{"count": 1})
+	if err == nil {
+		t.Fatalf("Expected error to occur")
+	}
+
+	if err.Error() != "Records must be a slice but got geckoboard.Record" {
+		t.Errorf("Expected records slice error, got %q", err.Error())
+	}
+}
+
+func TestDatasetsAppendBatches(t *testing.T) {
+	recs := []Record{{"count": 1}, {"count": 2}, {"count": 3}}
+
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	var counts []int
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Fatalf("Expected POST request, got %q", r.Method)
+		}
+
+		var body struct {
+			Data []Record `json:"data"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&body)
+		counts = append(counts, len(body.Data))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct{}{})
+	}))
+
+	c := New(Config{URL: s.URL, BatchSize: 2})
+
+	err := d.Append(c, recs, "")
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+
+	if !reflect.DeepEqual(counts, []int{2, 1}) {
+		t.Errorf("Expected batches of [2 1] records, got %v", counts)
+	}
+}
+
+func TestDatasetsAppendRecordLimit(t *testing.T) {
+	recs := []Record{{"count": 1}, {"count": 2}, {"count": 3}}
+
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	var sent int
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Data []Record `json:"data"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&body)
+		sent += len(body.Data)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct{}{})
+	}))
+
+	c := New(Config{URL: s.URL, RecordLimit: 2})
+
+	err := d.Append(c, recs, "")
+	if err == nil {
+		t.Fatalf("Expected error to occur")
+	}
+
+	expected := "Dataset 'foobar' can hold at most 2 records but 3 were sent"
+	if err.Error() != expected {
+		t.Errorf("Expected error %q, got %q", expected, err.Error())
+	}
+
+	if sent != 0 {
+		t.Errorf("Expected no records to be sent, got %d", sent)
+	}
+
+	err = d.Append(c, recs, "date")
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+
+	if sent != 2 {
+		t.Errorf("Expected records to be trimmed to 2, got %d", sent)
+	}
+}
+
+func TestDatasetsFind(t *testing.T) {
+	d := DataSet{
+		ID: "foobar",
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Fatalf("Expected GET request, got %q", r.Method)
+		}
+
+		if r.URL.Path != "/datasets/foobar" {
+			t.Fatalf(`Expected path to be "/datasets/foobar", got %q`, r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(DataSet{
+			ID:     "foobar",
+			Fields: Fields{"count": Field{Name: "Count", Type: NumberFieldType}},
+		})
+	}))
+
+	c := New(Config{URL: s.URL})
+
+	err := d.Find(c)
+	if err != nil {
+		t.Fatalf("Expected not errors, got %v", err)
+	}
+
+	if d.Fields["count"].Type != NumberFieldType {
+		t.Fatalf("Expected the count field to be loaded, got %v", d.Fields)
+	}
+}
+
+func TestDatasetsCreateSchemaMismatch(t *testing.T) {
+	d := DataSet{
+		ID:     "foobar",
+		Fields: Fields{"count": Field{Name: "Count", Type: NumberFieldType}},
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(Error{InnerError: InnerError{"Conflict"}})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(DataSet{
+			ID:     "foobar",
+			Fields: Fields{"count": Field{Name: "Count", Type: StringFieldType}},
+		})
+	}))
+
+	c := New(Config{URL: s.URL})
+
+	err := d.FindOrCreate(c)
+	mErr, ok := err.(SchemaMismatchError)
+	if !ok {
+		t.Fatalf("Expected a SchemaMismatchError, got %#v", err)
+	}
+
+	if len(mErr.Diffs) != 1 || mErr.Diffs[0].Key != "count" {
+		t.Fatalf("Expected the count field to differ, got %v", mErr.Diffs)
+	}
+}
+
+func TestDatasetsCreateErrors(t *testing.T) {
+	testCases := []struct {
+		putStatus        int
+		getStatus        int
+		uniqueBy         []string
+		expectedRequests []string
+		err              string
+	}{
+		{
+			putStatus:        http.StatusBadRequest,
+			expectedRequests: []string{"PUT"},
+			err:              "Bad (dataset 'foobar', status 400)",
+		},
+		{
+			putStatus:        http.StatusConflict,
+			getStatus:        http.StatusNotFound,
+			expectedRequests: []string{"PUT", "GET"},
+			err: "Finding the schema of dataset 'foobar' after it conflicted failed with: " +
+				"Bad (dataset 'foobar', status 404)",
+		},
+		{
+			putStatus:        http.StatusConflict,
+			getStatus:        http.StatusOK,
+			uniqueBy:         []string{"count"},
+			expectedRequests: []string{"PUT", "GET"},
+			err:              "Dataset 'foobar' already exists with a different schema: unique_by is [] but [count] is expected",
+		},
+		{
+			putStatus:        http.StatusConflict,
+			getStatus:        http.StatusOK,
+			expectedRequests: []string{"PUT", "GET"},
+			err:              "Bad (dataset 'foobar', status 409)",
+		},
+	}
+
+	for i, tc := range testCases {
+		var requests []string
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method)
+
+			status := tc.putStatus
+			if r.Method == "GET" {
+				status = tc.getStatus
+			}
+
+			w.WriteHeader(status)
+			if status != http.StatusOK {
+				json.NewEncoder(w).Encode(Error{InnerError: InnerError{"Bad"}})
+				return
+			}
+
+			json.NewEncoder(w).Encode(DataSet{
+				ID:     "foobar",
+				Fields: Fields{"count": Field{Name: "Count", Type: NumberFieldType}},
+			})
+		}))
+
+		d := DataSet{
+			ID:       "foobar",
+			Fields:   Fields{"count": Field{Name: "Count", Type: NumberFieldType}},
+			UniqueBy: tc.uniqueBy,
+		}
+
+		err := d.FindOrCreate(New(Config{URL: s.URL}))
+		s.Close()
+
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+
+		if !reflect.DeepEqual(requests, tc.expectedRequests) {
+			t.Errorf("[spec %d] Expected requests %v but got %v", i, tc.expectedRequests, requests)
+		}
 	}
 }
@@ -1,7 +1,17 @@
 package geckoboard
 
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error is returned when Geckoboard responds with an error, holding the
+// message from Geckoboard along with the response status code and the ID
+// of the dataset the request was for.
 type Error struct {
 	InnerError InnerError `json:"error"`
+	StatusCode int        `json:"-"`
+	DataSetID  string     `json:"-"`
 }
 
 type InnerError struct {
@@ -9,5 +19,26 @@ type InnerError struct {
 }
 
 func (e Error) Error() string {
-	return e.InnerError.Message
+	msg := e.InnerError.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	if e.DataSetID == "" {
+		return fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+
+	return fmt.Sprintf("%s (dataset '%s', status %d)", msg, e.DataSetID, e.StatusCode)
+}
+
+// RecordLimitError is returned when more records are sent to a dataset than it
+// can hold and there is no way of knowing which records to remove.
+type RecordLimitError struct {
+	DataSetID string
+	Count     int
+	Limit     int
+}
+
+func (e RecordLimitError) Error() string {
+	return fmt.Sprintf("Dataset '%s' can hold at most %d records but %d were sent", e.DataSetID, e.Limit, e.Count)
 }
@@ -0,0 +1,344 @@
+// Package geckoboardtest provides a fake Geckoboard datasets API which keeps
+// the datasets and their records in memory, checking them as Geckoboard
+// does, so what reports send can be verified without a Geckoboard account.
+package geckoboardtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+)
+
+// Geckoboard's limits on datasets.
+const (
+	MaxFields         = 10
+	MaxRecordsPerSend = 500
+	MaxRecords        = 5000
+)
+
+// Geckoboard is a fake Geckoboard API. It accepts any API key but requires
+// one to be sent. Use it as the handler of an httptest.Server or any other
+// http.Server.
+type Geckoboard struct {
+	// RecordLimit is the most records a dataset can hold, which defaults
+	// to MaxRecords.
+	RecordLimit int
+
+	mu       sync.Mutex
+	datasets map[string]*dataset
+	requests []string
+	failures []failure
+}
+
+type dataset struct {
+	schema  gb.DataSet
+	records []gb.Record
+}
+
+// failure is a response sent in place of the next request's.
+type failure struct {
+	status  int
+	message string
+}
+
+// New returns a fake Geckoboard without any datasets.
+func New() *Geckoboard {
+	return &Geckoboard{datasets: map[string]*dataset{}}
+}
+
+// Fail makes the next n requests fail with the status and message.
+func (g *Geckoboard) Fail(n, status int, message string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		g.failures = append(g.failures, failure{status: status, message: message})
+	}
+}
+
+// Requests returns the method and path of each request received, in order,
+// such as "PUT /datasets/tickets/data".
+func (g *Geckoboard) Requests() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]string(nil), g.requests...)
+}
+
+// DataSets returns the IDs of the datasets in order.
+func (g *Geckoboard) DataSets() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ids := []string{}
+	for id := range g.datasets {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// DataSet returns the schema of the dataset and whether it exists.
+func (g *Geckoboard) DataSet(id string) (gb.DataSet, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ds, ok := g.datasets[id]
+	if !ok {
+		return gb.DataSet{}, false
+	}
+
+	return ds.schema, true
+}
+
+// Records returns the records in the dataset, decoded from JSON so numbers
+// are float64s.
+func (g *Geckoboard) Records(id string) []gb.Record {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ds, ok := g.datasets[id]
+	if !ok {
+		return nil
+	}
+
+	return append([]gb.Record(nil), ds.records...)
+}
+
+func (g *Geckoboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.requests = append(g.requests, r.Method+" "+r.URL.Path)
+
+	if len(g.failures) > 0 {
+		f := g.failures[0]
+		g.failures = g.failures[1:]
+
+		writeError(w, f.status, f.message)
+		return
+	}
+
+	if key, _, ok := r.BasicAuth(); !ok || key == "" {
+		writeError(w, http.StatusUnauthorized, "Your API key is invalid")
+		return
+	}
+
+	if r.URL.Path == "/" && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, struct{}{})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/datasets/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/datasets/") || len(parts) > 2 || (len(parts) == 2 && parts[1] != "data") {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The fake Geckoboard doesn't serve %s", r.URL.Path))
+		return
+	}
+
+	id := parts[0]
+	route := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/datasets/"+id)
+
+	switch route {
+	case "PUT ":
+		g.findOrCreate(w, r, id)
+	case "GET ":
+		g.find(w, id)
+	case "DELETE ":
+		g.delete(w, id)
+	case "PUT /data":
+		g.replace(w, r, id)
+	case "POST /data":
+		g.append(w, r, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("The fake Geckoboard doesn't support %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func (g *Geckoboard) findOrCreate(w http.ResponseWriter, r *http.Request, id string) {
+	var schema gb.DataSet
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		writeError(w, http.StatusBadRequest, "The dataset schema is not valid JSON")
+		return
+	}
+
+	if err := validateSchema(id, &schema); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if ds, ok := g.datasets[id]; ok {
+		if len(gb.CompareFields(schema.Fields, ds.schema.Fields)) > 0 || !gb.SameUniqueBy(schema.UniqueBy, ds.schema.UniqueBy) {
+			writeError(w, http.StatusConflict, fmt.Sprintf("The dataset '%s' already exists with a different schema", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ds.schema)
+		return
+	}
+
+	now := time.Now().UTC()
+	schema.ID, schema.CreatedAt, schema.UpdatedAt = id, now, now
+	g.datasets[id] = &dataset{schema: schema}
+
+	writeJSON(w, http.StatusCreated, schema)
+}
+
+func (g *Geckoboard) find(w http.ResponseWriter, id string) {
+	ds, ok := g.datasets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The dataset '%s' was not found", id))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ds.schema)
+}
+
+func (g *Geckoboard) delete(w http.ResponseWriter, id string) {
+	if _, ok := g.datasets[id]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The dataset '%s' was not found", id))
+		return
+	}
+
+	delete(g.datasets, id)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// replace replaces all the records in the dataset with those sent.
+func (g *Geckoboard) replace(w http.ResponseWriter, r *http.Request, id string) {
+	ds, body, ok := g.readRecords(w, r, id)
+	if !ok {
+		return
+	}
+
+	if len(body.Data) > g.recordLimit() {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("The dataset can hold at most %d records but %d were sent", g.recordLimit(), len(body.Data)))
+		return
+	}
+
+	ds.records = body.Data
+	ds.schema.UpdatedAt = time.Now().UTC()
+
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// append adds the records to the dataset, replacing those with the same
+// values of the unique_by fields. When the dataset then holds more records
+// than it can the oldest are removed, by the delete_by field when given
+// otherwise in the order they were added.
+func (g *Geckoboard) append(w http.ResponseWriter, r *http.Request, id string) {
+	ds, body, ok := g.readRecords(w, r, id)
+	if !ok {
+		return
+	}
+
+	if body.DeleteBy != "" {
+		f, ok := ds.schema.Fields[body.DeleteBy]
+		if !ok || (f.Type != gb.DateFieldType && f.Type != gb.DatetimeFieldType) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("The delete_by field '%s' must be a date or datetime field of the dataset", body.DeleteBy))
+			return
+		}
+	}
+
+	for _, rec := range body.Data {
+		replaced := false
+
+		if len(ds.schema.UniqueBy) > 0 {
+			for i, existing := range ds.records {
+				if sameValues(existing, rec, ds.schema.UniqueBy) {
+					ds.records[i], replaced = rec, true
+					break
+				}
+			}
+		}
+
+		if !replaced {
+			ds.records = append(ds.records, rec)
+		}
+	}
+
+	if extra := len(ds.records) - g.recordLimit(); extra > 0 {
+		if body.DeleteBy != "" {
+			// Dates and datetimes sort in the order they are as strings.
+			sort.SliceStable(ds.records, func(i, j int) bool {
+				return fmt.Sprint(ds.records[i][body.DeleteBy]) < fmt.Sprint(ds.records[j][body.DeleteBy])
+			})
+		}
+
+		ds.records = ds.records[extra:]
+	}
+
+	ds.schema.UpdatedAt = time.Now().UTC()
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+type recordsBody struct {
+	Data     []gb.Record `json:"data"`
+	DeleteBy string      `json:"delete_by"`
+}
+
+// readRecords reads and checks the records sent to the dataset, responding
+// with the problem and returning false when they aren't valid.
+func (g *Geckoboard) readRecords(w http.ResponseWriter, r *http.Request, id string) (*dataset, *recordsBody, bool) {
+	ds, ok := g.datasets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The dataset '%s' was not found", id))
+		return nil, nil, false
+	}
+
+	var body recordsBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Data == nil {
+		writeError(w, http.StatusBadRequest, "The records must be sent as a JSON list under data")
+		return nil, nil, false
+	}
+
+	if len(body.Data) > MaxRecordsPerSend {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("At most %d records can be sent at once but %d were sent", MaxRecordsPerSend, len(body.Data)))
+		return nil, nil, false
+	}
+
+	for i, rec := range body.Data {
+		if err := validateRecord(ds.schema.Fields, rec); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Record %d is not valid: %s", i+1, err.Error()))
+			return nil, nil, false
+		}
+	}
+
+	return ds, &body, true
+}
+
+func (g *Geckoboard) recordLimit() int {
+	if g.RecordLimit > 0 {
+		return g.RecordLimit
+	}
+
+	return MaxRecords
+}
+
+func sameValues(a, b gb.Record, keys []string) bool {
+	for _, k := range keys {
+		if fmt.Sprint(a[k]) != fmt.Sprint(b[k]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError responds with the status and an error described as
+// Geckoboard does, which the client reads the message from.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, gb.Error{InnerError: gb.InnerError{Message: message}})
+}
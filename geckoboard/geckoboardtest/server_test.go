@@ -0,0 +1,184 @@
+package geckoboardtest
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+)
+
+type countRecord struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+func TestDataSetLifecycle(t *testing.T) {
+	fake := New()
+	fake.RecordLimit = 3
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := gb.New(gb.Config{Key: "abc", URL: server.URL})
+
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Unexpected error got %s", err)
+	}
+
+	ds := gb.DataSet{
+		ID: "tickets.by.day",
+		Fields: gb.Fields{
+			"date":  gb.Field{Type: gb.DateFieldType, Name: "Date"},
+			"count": gb.Field{Type: gb.NumberFieldType, Name: "Count"},
+		},
+		UniqueBy: []string{"date"},
+	}
+
+	if err := ds.FindOrCreate(c); err != nil {
+		t.Fatalf("Unexpected error got %s", err)
+	}
+
+	if ds.CreatedAt.IsZero() {
+		t.Error("Expected the dataset to have been given its created date")
+	}
+
+	// Creating it again with the same schema finds it.
+	if err := ds.FindOrCreate(c); err != nil {
+		t.Fatalf("Unexpected error got %s", err)
+	}
+
+	if err := ds.SendAll(c, []countRecord{{"2017-01-01", 1}, {"2017-01-02", 2}}); err != nil {
+		t.Fatalf("Unexpected error got %s", err)
+	}
+
+	err := ds.Append(c, []countRecord{{"2017-01-02", 5}, {"2017-01-04", 4}, {"2017-01-03", 3}}, "date")
+	if err != nil {
+		t.Fatalf("Unexpected error got %s", err)
+	}
+
+	expected := []gb.Record{
+		{"date": "2017-01-02", "count": float64(5)},
+		{"date": "2017-01-03", "count": float64(3)},
+		{"date": "2017-01-04", "count": float64(4)},
+	}
+
+	if records := fake.Records("tickets.by.day"); !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected records %v but got %v", expected, records)
+	}
+
+	changed := gb.DataSet{ID: "tickets.by.day", Fields: gb.Fields{"count": gb.Field{Type: gb.StringFieldType, Name: "Count"}}}
+	err = changed.FindOrCreate(c)
+	if _, ok := err.(gb.SchemaMismatchError); !ok {
+		t.Errorf("Expected a SchemaMismatchError but got %v", err)
+	}
+
+	if err := ds.Delete(c); err != nil {
+		t.Fatalf("Unexpected error got %s", err)
+	}
+
+	if ids := fake.DataSets(); len(ids) != 0 {
+		t.Errorf("Expected no datasets after deleting but got %v", ids)
+	}
+
+	expectedRequests := []string{
+		"GET /",
+		"PUT /datasets/tickets.by.day",
+		"PUT /datasets/tickets.by.day",
+		"PUT /datasets/tickets.by.day/data",
+		"POST /datasets/tickets.by.day/data",
+		"PUT /datasets/tickets.by.day",
+		"GET /datasets/tickets.by.day",
+		"DELETE /datasets/tickets.by.day",
+	}
+
+	if !reflect.DeepEqual(fake.Requests(), expectedRequests) {
+		t.Errorf("Expected requests %v but got %v", expectedRequests, fake.Requests())
+	}
+}
+
+func TestDataSetErrors(t *testing.T) {
+	fake := New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := gb.New(gb.Config{Key: "abc", URL: server.URL, MaxRetries: 1, RetryWait: 1})
+
+	ds := gb.DataSet{
+		ID: "tickets",
+		Fields: gb.Fields{
+			"count":   gb.Field{Type: gb.NumberFieldType, Name: "Count"},
+			"average": gb.Field{Type: gb.DurationFieldType, Name: "Average", TimeUnit: gb.Minutes, Optional: true},
+		},
+	}
+
+	if err := ds.FindOrCreate(c); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		send func() error
+		err  string
+	}{
+		{
+			send: func() error { return gb.New(gb.Config{URL: server.URL}).Ping() },
+			err:  "Your API key is invalid (status 401)",
+		},
+		{
+			send: func() error {
+				bad := gb.DataSet{ID: "Tickets", Fields: gb.Fields{"count": gb.Field{Type: gb.NumberFieldType, Name: "Count"}}}
+				return bad.FindOrCreate(c)
+			},
+			err: "The dataset id 'Tickets' can only contain lowercase letters, numbers, dots, hyphens and underscores (dataset 'Tickets', status 400)",
+		},
+		{
+			send: func() error {
+				bad := gb.DataSet{ID: "money", Fields: gb.Fields{"amount": gb.Field{Type: gb.MoneyFieldType, Name: "Amount"}}}
+				return bad.FindOrCreate(c)
+			},
+			err: "The money field 'amount' must have a 3 letter currency_code (dataset 'money', status 400)",
+		},
+		{
+			send: func() error { return ds.SendAll(c, []gb.Record{{"count": 1, "average": nil}}) },
+		},
+		{
+			send: func() error { return ds.SendAll(c, []gb.Record{{"count": "one"}}) },
+			err:  "Record 1 is not valid: the field 'count' must be a number but got one (dataset 'tickets', status 400)",
+		},
+		{
+			send: func() error { return ds.SendAll(c, []gb.Record{{"average": 1}}) },
+			err:  "Record 1 is not valid: the field 'count' is required (dataset 'tickets', status 400)",
+		},
+		{
+			send: func() error { return ds.SendAll(c, []gb.Record{{"count": 1, "total": 2}}) },
+			err:  "Record 1 is not valid: 'total' is not a field of the dataset (dataset 'tickets', status 400)",
+		},
+		{
+			send: func() error {
+				fake.Fail(1, 503, "Down for maintenance")
+				return ds.SendAll(c, []gb.Record{{"count": 2}})
+			},
+		},
+		{
+			send: func() error { return ds.Append(c, []gb.Record{{"count": 3}}, "count") },
+			err:  "The delete_by field 'count' must be a date or datetime field of the dataset (dataset 'tickets', status 400)",
+		},
+	}
+
+	for i, tc := range testCases {
+		err := tc.send()
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+	}
+
+	expected := []gb.Record{{"count": float64(2)}}
+	if records := fake.Records("tickets"); !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected records %v after the retried request but got %v", expected, records)
+	}
+}
@@ -0,0 +1,155 @@
+package geckoboardtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+)
+
+var (
+	validID  = regexp.MustCompile(`^[a-z0-9._-]+$`)
+	validKey = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+	validTimeUnits = []string{gb.Milliseconds, gb.Seconds, gb.Minutes, gb.Hours}
+)
+
+// validateSchema checks the dataset ID and fields as Geckoboard does when
+// creating a dataset.
+func validateSchema(id string, schema *gb.DataSet) error {
+	if !validID.MatchString(id) {
+		return fmt.Errorf("The dataset id '%s' can only contain lowercase letters, numbers, dots, hyphens and underscores", id)
+	}
+
+	if len(schema.Fields) == 0 {
+		return errors.New("The dataset must have at least one field")
+	}
+
+	if len(schema.Fields) > MaxFields {
+		return fmt.Errorf("The dataset can have at most %d fields but has %d", MaxFields, len(schema.Fields))
+	}
+
+	// Check the fields in order so the same problem is always reported.
+	keys := []string{}
+	for k := range schema.Fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := validateField(k, schema.Fields[k]); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range schema.UniqueBy {
+		if _, ok := schema.Fields[k]; !ok {
+			return fmt.Errorf("The unique_by field '%s' is not a field of the dataset", k)
+		}
+	}
+
+	return nil
+}
+
+func validateField(key string, f gb.Field) error {
+	if !validKey.MatchString(key) {
+		return fmt.Errorf("The field key '%s' can only contain lowercase letters, numbers and underscores", key)
+	}
+
+	if f.Name == "" {
+		return fmt.Errorf("The field '%s' is missing its name", key)
+	}
+
+	switch f.Type {
+	case gb.NumberFieldType, gb.DateFieldType, gb.DatetimeFieldType, gb.StringFieldType, gb.PercentageFieldType:
+	case gb.MoneyFieldType:
+		if len(f.CurrencyCode) != 3 {
+			return fmt.Errorf("The money field '%s' must have a 3 letter currency_code", key)
+		}
+	case gb.DurationFieldType:
+		if !contains(validTimeUnits, f.TimeUnit) {
+			return fmt.Errorf("The duration field '%s' must have a time_unit, one of %v", key, validTimeUnits)
+		}
+	default:
+		return fmt.Errorf("The field '%s' has an unknown type '%s'", key, f.Type)
+	}
+
+	return nil
+}
+
+// validateRecord checks the record has a valid value for each of the
+// fields, allowing optional ones to be null or missing, and no others.
+func validateRecord(fields gb.Fields, rec gb.Record) error {
+	keys := []string{}
+	for k := range rec {
+		keys = append(keys, k)
+	}
+
+	for k := range fields {
+		if _, ok := rec[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		f, ok := fields[k]
+		if !ok {
+			return fmt.Errorf("'%s' is not a field of the dataset", k)
+		}
+
+		v, ok := rec[k]
+		if !ok || v == nil {
+			if f.Optional {
+				continue
+			}
+
+			return fmt.Errorf("the field '%s' is required", k)
+		}
+
+		if !validValue(f.Type, v) {
+			return fmt.Errorf("the field '%s' must be a %s but got %v", k, f.Type, v)
+		}
+	}
+
+	return nil
+}
+
+func validValue(fieldType string, v interface{}) bool {
+	switch fieldType {
+	case gb.NumberFieldType, gb.PercentageFieldType, gb.DurationFieldType:
+		_, ok := v.(float64)
+		return ok
+	case gb.MoneyFieldType:
+		// Money is sent in the currency's smallest unit, such as cents.
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case gb.StringFieldType:
+		_, ok := v.(string)
+		return ok
+	case gb.DateFieldType:
+		s, ok := v.(string)
+		_, err := time.Parse("2006-01-02", s)
+		return ok && err == nil
+	case gb.DatetimeFieldType:
+		s, ok := v.(string)
+		_, err := time.Parse(time.RFC3339, s)
+		return ok && err == nil
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
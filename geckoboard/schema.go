@@ -0,0 +1,118 @@
+package geckoboard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldDiff describes a field that differs between the expected schema and
+// the schema of an existing dataset. Expected is nil when the field only
+// exists on the dataset and Actual is nil when the dataset is missing it.
+type FieldDiff struct {
+	Key      string
+	Expected *Field
+	Actual   *Field
+}
+
+func (d FieldDiff) String() string {
+	switch {
+	case d.Actual == nil:
+		return fmt.Sprintf("field '%s' (%s) is missing from the dataset", d.Key, d.Expected.Type)
+	case d.Expected == nil:
+		return fmt.Sprintf("field '%s' (%s) is no longer expected", d.Key, d.Actual.Type)
+	case d.Expected.Type != d.Actual.Type:
+		return fmt.Sprintf("field '%s' has type %s but %s is expected", d.Key, d.Actual.Type, d.Expected.Type)
+	case d.Expected.Name != d.Actual.Name:
+		return fmt.Sprintf("field '%s' is named '%s' but '%s' is expected", d.Key, d.Actual.Name, d.Expected.Name)
+	case d.Expected.CurrencyCode != d.Actual.CurrencyCode:
+		return fmt.Sprintf("field '%s' has currency code %s but %s is expected", d.Key, d.Actual.CurrencyCode, d.Expected.CurrencyCode)
+	case d.Expected.TimeUnit != d.Actual.TimeUnit:
+		return fmt.Sprintf("field '%s' has time unit %s but %s is expected", d.Key, d.Actual.TimeUnit, d.Expected.TimeUnit)
+	}
+
+	return fmt.Sprintf("field '%s' has optional %t but %t is expected", d.Key, d.Actual.Optional, d.Expected.Optional)
+}
+
+// CompareFields returns the differences between the expected and actual
+// fields ordered by the field key, or nil if they are the same.
+func CompareFields(expected, actual Fields) []FieldDiff {
+	keys := []string{}
+	for k := range expected {
+		keys = append(keys, k)
+	}
+
+	for k := range actual {
+		if _, ok := expected[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	var diffs []FieldDiff
+	for _, k := range keys {
+		e, inExpected := expected[k]
+		a, inActual := actual[k]
+
+		switch {
+		case !inActual:
+			diffs = append(diffs, FieldDiff{Key: k, Expected: &e})
+		case !inExpected:
+			diffs = append(diffs, FieldDiff{Key: k, Actual: &a})
+		case e != a:
+			diffs = append(diffs, FieldDiff{Key: k, Expected: &e, Actual: &a})
+		}
+	}
+
+	return diffs
+}
+
+// SameUniqueBy reports whether the expected and actual unique_by fields are
+// the same, in the same order.
+func SameUniqueBy(expected, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	for i := range expected {
+		if expected[i] != actual[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SchemaMismatchError is returned when a dataset already exists with a
+// schema different to the one expected. UniqueBy holds the expected and
+// actual unique_by fields when they differ.
+type SchemaMismatchError struct {
+	DataSetID string
+	Diffs     []FieldDiff
+	UniqueBy  *UniqueByDiff
+}
+
+// UniqueByDiff describes the unique_by fields expected and those of an
+// existing dataset when they differ.
+type UniqueByDiff struct {
+	Expected []string
+	Actual   []string
+}
+
+func (d UniqueByDiff) String() string {
+	return fmt.Sprintf("unique_by is [%s] but [%s] is expected", strings.Join(d.Actual, ", "), strings.Join(d.Expected, ", "))
+}
+
+func (e SchemaMismatchError) Error() string {
+	diffs := make([]string, len(e.Diffs))
+	for i, d := range e.Diffs {
+		diffs[i] = d.String()
+	}
+
+	if e.UniqueBy != nil {
+		diffs = append(diffs, e.UniqueBy.String())
+	}
+
+	return fmt.Sprintf("Dataset '%s' already exists with a different schema: %s", e.DataSetID, strings.Join(diffs, ", "))
+}
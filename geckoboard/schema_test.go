@@ -0,0 +1,105 @@
+package geckoboard
+
+import "testing"
+
+func TestCompareFields(t *testing.T) {
+	testCases := []struct {
+		expected Fields
+		actual   Fields
+		out      []string
+	}{
+		{
+			expected: Fields{"count": Field{Name: "Count", Type: NumberFieldType}},
+			actual:   Fields{"count": Field{Name: "Count", Type: NumberFieldType}},
+		},
+		{
+			expected: Fields{
+				"count": Field{Name: "Count", Type: NumberFieldType},
+				"date":  Field{Name: "Date", Type: DateFieldType},
+			},
+			actual: Fields{
+				"count":      Field{Name: "Count", Type: StringFieldType},
+				"grouped_by": Field{Name: "Tags", Type: StringFieldType},
+			},
+			out: []string{
+				"field 'count' has type string but number is expected",
+				"field 'date' (date) is missing from the dataset",
+				"field 'grouped_by' (string) is no longer expected",
+			},
+		},
+		{
+			expected: Fields{"grouped_by": Field{Name: "Status", Type: StringFieldType}},
+			actual:   Fields{"grouped_by": Field{Name: "Tags", Type: StringFieldType}},
+			out:      []string{"field 'grouped_by' is named 'Tags' but 'Status' is expected"},
+		},
+	}
+
+	for i, tc := range testCases {
+		diffs := CompareFields(tc.expected, tc.actual)
+
+		if len(diffs) != len(tc.out) {
+			t.Errorf("[spec %d] Expected %d differences but got %d", i, len(tc.out), len(diffs))
+			continue
+		}
+
+		for j, d := range diffs {
+			if d.String() != tc.out[j] {
+				t.Errorf("[spec %d] Expected difference %q but got %q", i, tc.out[j], d.String())
+			}
+		}
+	}
+}
+
+func TestSchemaMismatchError(t *testing.T) {
+	err := SchemaMismatchError{
+		DataSetID: "foobar",
+		Diffs: CompareFields(
+			Fields{"count": Field{Name: "Count", Type: NumberFieldType}},
+			Fields{"total": Field{Name: "Total", Type: NumberFieldType}},
+		),
+	}
+
+	expected := "Dataset 'foobar' already exists with a different schema: " +
+		"field 'count' (number) is missing from the dataset, field 'total' (number) is no longer expected"
+
+	if err.Error() != expected {
+		t.Errorf("Expected error %q but got %q", expected, err.Error())
+	}
+}
+
+func TestCompareFieldAttributes(t *testing.T) {
+	testCases := []struct {
+		expected Field
+		actual   Field
+		out      string
+	}{
+		{
+			expected: Field{Name: "Revenue", Type: MoneyFieldType, CurrencyCode: "USD"},
+			actual:   Field{Name: "Revenue", Type: MoneyFieldType, CurrencyCode: "GBP"},
+			out:      "field 'x' has currency code GBP but USD is expected",
+		},
+		{
+			expected: Field{Name: "Reply", Type: DurationFieldType, TimeUnit: Minutes},
+			actual:   Field{Name: "Reply", Type: DurationFieldType, TimeUnit: Hours},
+			out:      "field 'x' has time unit hours but minutes is expected",
+		},
+		{
+			expected: Field{Name: "Rate", Type: PercentageFieldType, Optional: true},
+			actual:   Field{Name: "Rate", Type: PercentageFieldType},
+			out:      "field 'x' has optional false but true is expected",
+		},
+	}
+
+	for i, tc := range testCases {
+		diffs := CompareFields(Fields{"x": tc.expected}, Fields{"x": tc.actual})
+
+		if len(diffs) != 1 {
+			t.Errorf("[spec %d] Expected 1 difference but got %d", i, len(diffs))
+			continue
+		}
+
+		if diffs[0].String() != tc.out {
+			t.Errorf("[spec %d] Expected difference %q but got %q", i, tc.out, diffs[0].String())
+		}
+	}
+}
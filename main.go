@@ -5,36 +5,60 @@ import (
 	"fmt"
 	"log"
 	"os"
-
-	"github.com/geckoboard/zendesk_dataset/conf"
-	"github.com/geckoboard/zendesk_dataset/zendesk"
 )
 
 var (
 	configPath     = flag.String("config", "./geckoboard_zendesk.conf", "Path to your geckoboard zendesk configuration")
 	displayVersion = flag.Bool("version", false, "Prints version of Zendesk Dataset")
+	onlyReports    = flag.String("only", "", "Comma separated datasets, report names or tags of the reports to run, datasets can use * wildcards")
+	exceptReports  = flag.String("except", "", "Comma separated datasets, report names or tags of the reports not to run")
+	fakeZendesk    = flag.String("fake-zendesk", "", "Path to a JSON file of tickets to serve from a local fake Zendesk used in place of the real one")
+	recordPath     = flag.String("record", "", "Path to a cassette file to record the Zendesk and Geckoboard requests and responses to")
+	replayPath     = flag.String("replay", "", "Path to a cassette file to replay the recorded responses from, in place of connecting to Zendesk and Geckoboard")
+	asOfDate       = flag.String("as-of", "", "Date such as 2016-01-31 to run the reports as of, in place of today")
 )
 
 const version = "0.2.0"
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
+	// The -version flag predates the version command and is kept
+	// for those already using it.
 	if *displayVersion {
 		fmt.Printf("Version: %s\n", version)
 		os.Exit(0)
 	}
 
-	config, err := conf.LoadConfig(*configPath)
+	// Running without a command runs all the reports as it always has.
+	name, args := "run", flag.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(args)
+	saveRecording()
 
 	if err != nil {
-		log.Fatalf("ERRO: Problem with the config: %s\n", err.Error())
+		log.Fatalf("ERRO: %s\n", err.Error())
 	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: zendesk_dataset [-config path] [command] [arguments]\n\nCommands:\n")
 
-	if len(config.Zendesk.Reports) == 0 {
-		log.Fatal("ERRO: You have no reports setup in your config under zendesk")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
 	}
 
-	zendesk.HandleReports(config)
-	log.Println("Completed processing all reports...")
+	fmt.Fprintf(os.Stderr, "\nWithout a command all the reports are run. Flags:\n")
+	flag.PrintDefaults()
 }
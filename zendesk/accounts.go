@@ -0,0 +1,135 @@
+package zendesk
+
+import (
+	"fmt"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+// TicketSearcher searches for tickets in one or more Zendesk accounts, or
+// in the warehouse, for a report's template to build its records from.
+type TicketSearcher interface {
+	// CountTickets returns the number of tickets the query finds without
+	// fetching them all.
+	CountTickets(q *Query) (int, error)
+	// SearchTickets returns all the tickets the query finds.
+	SearchTickets(q *Query) (*TicketPayload, error)
+	// TicketMetrics returns all the tickets the query finds with their
+	// metric sets.
+	TicketMetrics(q *Query) (*TicketMetrics, error)
+}
+
+// accountsClient searches several Zendesk accounts with the same query
+// adding the counts and tickets from each together.
+type accountsClient []*Client
+
+// newReportClient returns a client for the Zendesk accounts the report
+// uses, which searches them all when it uses more than one, reusing the
+// search results and metric sets in the runner's cache. Reports with the
+// warehouse source search the runner's warehouse instead.
+func newReportClient(rn *Runner, r *conf.Report) (TicketSearcher, error) {
+	c := rn.config
+
+	auths, err := c.ZendeskAuths(r)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := r.TicketSource()
+	if err != nil {
+		return nil, err
+	}
+
+	if source == conf.WarehouseSource {
+		w, err := rn.openWarehouse(c.Zendesk.Warehouse.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		ws := warehouseSearcher{warehouse: w}
+		for i := range auths {
+			ws.accounts = append(ws.accounts, accountName(&auths[i]))
+		}
+
+		return ws, nil
+	}
+
+	if len(auths) == 1 {
+		client, err := rn.newClient(&auths[0])
+		if err != nil {
+			return nil, err
+		}
+
+		client.cache = rn.cache
+		return client, nil
+	}
+
+	clients := make(accountsClient, len(auths))
+	for i := range auths {
+		if clients[i], err = rn.newClient(&auths[i]); err != nil {
+			return nil, err
+		}
+
+		clients[i].cache = rn.cache
+	}
+
+	return clients, nil
+}
+
+// CountTickets searches each account returning the total count.
+func (ac accountsClient) CountTickets(q *Query) (int, error) {
+	var total int
+
+	for _, c := range ac {
+		cq := *q
+
+		count, err := c.CountTickets(&cq)
+		if err != nil {
+			return 0, fmt.Errorf("Searching Zendesk account '%s' failed with: %s", c.account(), err.Error())
+		}
+
+		total += count
+	}
+
+	return total, nil
+}
+
+// SearchTickets searches each account returning the total count and,
+// when paginated, the tickets from all of them.
+func (ac accountsClient) SearchTickets(q *Query) (*TicketPayload, error) {
+	var total TicketPayload
+
+	for _, c := range ac {
+		// SearchTickets sets the endpoint so give each its own query.
+		cq := *q
+
+		tp, err := c.SearchTickets(&cq)
+		if err != nil {
+			return nil, fmt.Errorf("Searching Zendesk account '%s' failed with: %s", c.account(), err.Error())
+		}
+
+		total.Count += tp.Count
+		total.Tickets = append(total.Tickets, tp.Tickets...)
+	}
+
+	return &total, nil
+}
+
+// TicketMetrics returns the tickets with their metrics from each account.
+func (ac accountsClient) TicketMetrics(q *Query) (*TicketMetrics, error) {
+	var total TicketMetrics
+
+	for _, c := range ac {
+		cq := *q
+
+		tm, err := c.TicketMetrics(&cq)
+		if err != nil {
+			return nil, fmt.Errorf("Searching Zendesk account '%s' failed with: %s", c.account(), err.Error())
+		}
+
+		total.Tickets = append(total.Tickets, tm.Tickets...)
+	}
+
+	total.Count = len(total.Tickets)
+	return &total, nil
+}
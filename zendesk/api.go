@@ -16,10 +16,12 @@ type MetricSet struct {
 	OnHoldTime          SubTimeMetric `json:"on_hold_time_in_minutes"`
 }
 
-// SubTimeMetric describe metrics with business and calendar values.
+// SubTimeMetric describe metrics with business and calendar values, which
+// are nil when Zendesk has no value such as the resolution time of an
+// unsolved ticket.
 type SubTimeMetric struct {
-	Business int `json:"business"`
-	Calendar int `json:"calendar"`
+	Business *int `json:"business"`
+	Calendar *int `json:"calendar"`
 }
 
 // TicketPayload the payload returned for search api for type:ticket.
@@ -39,7 +41,7 @@ type Ticket struct {
 
 // TicketMetrics is the tickets/show_many.json schema.
 type TicketMetrics struct {
-	Tickets []Ticket `json:tickets`
+	Tickets []Ticket `json:"tickets"`
 	Count   int      `json:"count"`
 }
 
@@ -61,3 +63,23 @@ func (t Ticket) subTimeMetric(attr conf.MetricAttribute) *SubTimeMetric {
 
 	return nil
 }
+
+// metricValue returns the ticket's metric in minutes for the attribute and
+// unit of the metric options, and false when the ticket has no value.
+func (t Ticket) metricValue(opts *conf.MetricOption) (int, bool) {
+	m := t.subTimeMetric(opts.Attribute)
+	if m == nil {
+		return 0, false
+	}
+
+	v := m.Calendar
+	if opts.Unit == conf.BusinessMetric {
+		v = m.Business
+	}
+
+	if v == nil {
+		return 0, false
+	}
+
+	return *v, true
+}
@@ -1,6 +1,7 @@
 package zendesk
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/geckoboard/zendesk_dataset/conf"
@@ -13,34 +14,34 @@ func TestSubMetricValue(t *testing.T) {
 		out    SubTimeMetric
 	}{
 		{
-			ticket: Ticket{Metrics: MetricSet{ReplyTime: SubTimeMetric{Business: 1, Calendar: 11}}},
+			ticket: Ticket{Metrics: MetricSet{ReplyTime: SubTimeMetric{Business: minutes(1), Calendar: minutes(11)}}},
 			in:     conf.ReplyTime,
-			out:    SubTimeMetric{Business: 1, Calendar: 11},
+			out:    SubTimeMetric{Business: minutes(1), Calendar: minutes(11)},
 		},
 		{
-			ticket: Ticket{Metrics: MetricSet{FirstResolutionTime: SubTimeMetric{Business: 2, Calendar: 22}}},
+			ticket: Ticket{Metrics: MetricSet{FirstResolutionTime: SubTimeMetric{Business: minutes(2), Calendar: minutes(22)}}},
 			in:     conf.FirstResolutionTime,
-			out:    SubTimeMetric{Business: 2, Calendar: 22},
+			out:    SubTimeMetric{Business: minutes(2), Calendar: minutes(22)},
 		},
 		{
-			ticket: Ticket{Metrics: MetricSet{FullResolutionTime: SubTimeMetric{Business: 3, Calendar: 33}}},
+			ticket: Ticket{Metrics: MetricSet{FullResolutionTime: SubTimeMetric{Business: minutes(3), Calendar: minutes(33)}}},
 			in:     conf.FullResolutionTime,
-			out:    SubTimeMetric{Business: 3, Calendar: 33},
+			out:    SubTimeMetric{Business: minutes(3), Calendar: minutes(33)},
 		},
 		{
-			ticket: Ticket{Metrics: MetricSet{AgentWaitTime: SubTimeMetric{Business: 4, Calendar: 44}}},
+			ticket: Ticket{Metrics: MetricSet{AgentWaitTime: SubTimeMetric{Business: minutes(4), Calendar: minutes(44)}}},
 			in:     conf.AgentWaitTime,
-			out:    SubTimeMetric{Business: 4, Calendar: 44},
+			out:    SubTimeMetric{Business: minutes(4), Calendar: minutes(44)},
 		},
 		{
-			ticket: Ticket{Metrics: MetricSet{RequesterWaitTime: SubTimeMetric{Business: 5, Calendar: 55}}},
+			ticket: Ticket{Metrics: MetricSet{RequesterWaitTime: SubTimeMetric{Business: minutes(5), Calendar: minutes(55)}}},
 			in:     conf.RequesterWaitTime,
-			out:    SubTimeMetric{Business: 5, Calendar: 55},
+			out:    SubTimeMetric{Business: minutes(5), Calendar: minutes(55)},
 		},
 		{
-			ticket: Ticket{Metrics: MetricSet{OnHoldTime: SubTimeMetric{Business: 6, Calendar: 66}}},
+			ticket: Ticket{Metrics: MetricSet{OnHoldTime: SubTimeMetric{Business: minutes(6), Calendar: minutes(66)}}},
 			in:     conf.OnHoldTime,
-			out:    SubTimeMetric{Business: 6, Calendar: 66},
+			out:    SubTimeMetric{Business: minutes(6), Calendar: minutes(66)},
 		},
 	}
 
@@ -51,8 +52,41 @@ func TestSubMetricValue(t *testing.T) {
 			t.Fatalf("Expected %#v but got nil", tc.out)
 		}
 
-		if *out != tc.out {
+		if !reflect.DeepEqual(*out, tc.out) {
 			t.Errorf("[spec %d] Expected %#v but got %#v", i, tc.out, *out)
 		}
 	}
 }
+
+// minutes returns a pointer to the metric value.
+func minutes(m int) *int {
+	return &m
+}
+
+func TestMetricValue(t *testing.T) {
+	ticket := Ticket{Metrics: MetricSet{
+		ReplyTime:          SubTimeMetric{Business: minutes(1), Calendar: minutes(11)},
+		FullResolutionTime: SubTimeMetric{Business: minutes(0)},
+	}}
+
+	testCases := []struct {
+		in  conf.MetricOption
+		out int
+		ok  bool
+	}{
+		{in: conf.MetricOption{Attribute: conf.ReplyTime, Unit: conf.BusinessMetric}, out: 1, ok: true},
+		{in: conf.MetricOption{Attribute: conf.ReplyTime, Unit: conf.CalendarMetric}, out: 11, ok: true},
+		{in: conf.MetricOption{Attribute: conf.FullResolutionTime, Unit: conf.BusinessMetric}, out: 0, ok: true},
+		{in: conf.MetricOption{Attribute: conf.FullResolutionTime, Unit: conf.CalendarMetric}},
+		{in: conf.MetricOption{Attribute: conf.OnHoldTime, Unit: conf.CalendarMetric}},
+		{in: conf.MetricOption{Attribute: "unknown", Unit: conf.CalendarMetric}},
+	}
+
+	for i, tc := range testCases {
+		out, ok := ticket.metricValue(&tc.in)
+
+		if out != tc.out || ok != tc.ok {
+			t.Errorf("[spec %d] Expected %d, %t but got %d, %t", i, tc.out, tc.ok, out, ok)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package zendesk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+// backfillRateLimitRetries is how many times a backfill retries a rate
+// limited Zendesk request, as it searches once for each report and day.
+const backfillRateLimitRetries = 5
+
+// backfillProgress is the days backfilled for the dataset of each report
+// in its Geckoboard account, and the sink they were sent to, kept in a JSON
+// file so an interrupted backfill carries on from there.
+type backfillProgress struct {
+	Sink    string                     `json:"sink"`
+	Reports map[string]*reportProgress `json:"reports"`
+
+	path string
+}
+
+// reportProgress is the first and the last day backfilled for a report.
+type reportProgress struct {
+	From string `json:"from"`
+	Done string `json:"done"`
+}
+
+// loadBackfillProgress reads the progress from the path, a missing file
+// being a backfill which hasn't started.
+func loadBackfillProgress(path string) (*backfillProgress, error) {
+	p := &backfillProgress{Reports: map[string]*reportProgress{}, path: path}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Reading the backfill progress failed with: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("Backfill progress %s is not valid: %s", path, err.Error())
+	}
+
+	if p.Reports == nil {
+		p.Reports = map[string]*reportProgress{}
+	}
+
+	return p, nil
+}
+
+// progressKey identifies the report's dataset in its Geckoboard account,
+// as reports sending to different accounts can share a dataset name.
+func progressKey(r *conf.Report) string {
+	account := r.GeckoboardAccount
+	if account == "" {
+		account = conf.DefaultAccount
+	}
+
+	return account + "/" + r.DataSet
+}
+
+// sinkName describes the sink in the progress, using its String method
+// when it has one.
+func sinkName(s Sink) string {
+	if n, ok := s.(fmt.Stringer); ok {
+		return n.String()
+	}
+
+	return fmt.Sprintf("%T", s)
+}
+
+// check returns an error when the progress is of a backfill to another
+// sink, or when the first day is before a report's backfill started or
+// after the day following the last it did, as the days between would
+// otherwise be left out.
+func (p *backfillProgress) check(sink string, reports []conf.Report, first time.Time) error {
+	if p.Sink != "" && p.Sink != sink {
+		return fmt.Errorf("Backfill progress %s is of sending the records to %s rather than %s, give another progress file",
+			p.path, p.Sink, sink)
+	}
+
+	for i := range reports {
+		r := &reports[i]
+		rp, ok := p.Reports[progressKey(r)]
+		if !ok {
+			continue
+		}
+
+		date := first.Format(dateFormat)
+		if date < rp.From {
+			return fmt.Errorf("Backfill progress %s has report '%s' starting on %s, remove it to backfill from %s",
+				p.path, r.DataSet, rp.From, date)
+		}
+
+		done, err := time.Parse(dateFormat, rp.Done)
+		if err != nil {
+			return fmt.Errorf("Backfill progress %s is not valid: %s", p.path, err.Error())
+		}
+
+		if next := done.AddDate(0, 0, 1).Format(dateFormat); date > next {
+			return fmt.Errorf("Backfill progress %s has report '%s' done to %s, backfill from %s or before to leave no gap",
+				p.path, r.DataSet, rp.Done, next)
+		}
+	}
+
+	return nil
+}
+
+func (p *backfillProgress) save() error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceFile(p.path, b); err != nil {
+		return fmt.Errorf("Saving the backfill progress failed with: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Backfill runs the config's reports as of each day from the first to the
+// last, sending the records of each day with its date to the sink so new
+// datasets start with history. Only reports in append mode build up
+// history so the others are skipped.
+//
+// The days done for each report are saved to the progress file at the path
+// after each day, so running the backfill again after it fails or is
+// interrupted carries on from the day after. The progress is refused when
+// it is of another sink, or of a backfill starting after the first day.
+// Rate limited Zendesk requests are retried after waiting as long as
+// Zendesk asks.
+func (rn *Runner) Backfill(first, last time.Time, progressPath string) error {
+	if last.Before(first) {
+		return fmt.Errorf("The backfill's first day %s is after its last day %s",
+			first.Format(dateFormat), last.Format(dateFormat))
+	}
+
+	progress, err := loadBackfillProgress(progressPath)
+	if err != nil {
+		return err
+	}
+
+	var reports []conf.Report
+	for _, r := range rn.config.Zendesk.Reports {
+		if mode, _ := r.SendMode(); mode != conf.AppendMode {
+			rn.logger.Printf("WARN: Skipping report '%s' as only reports in append mode build up history", r.DataSet)
+			continue
+		}
+
+		reports = append(reports, r)
+	}
+
+	if len(reports) == 0 {
+		return errors.New("None of the reports are in append mode, which backfilling needs")
+	}
+
+	sink := sinkName(rn.sink)
+	if err := progress.check(sink, reports, first); err != nil {
+		return err
+	}
+
+	progress.Sink = sink
+
+	if rn.rateLimitRetries < backfillRateLimitRetries {
+		rn.rateLimitRetries = backfillRateLimitRetries
+	}
+
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateFormat)
+
+		for i := range reports {
+			r := &reports[i]
+
+			rp, ok := progress.Reports[progressKey(r)]
+			if ok && rp.Done >= date {
+				continue
+			}
+
+			data, err := rn.reportAsOf(boundedReport(r, day), day)
+			if err == nil {
+				err = rn.sink.Send(r, data)
+			}
+
+			if err != nil {
+				return fmt.Errorf("Backfilling report '%s' for %s failed with: %s", r.DataSet, date, err.Error())
+			}
+
+			if !ok {
+				rp = &reportProgress{From: date}
+				progress.Reports[progressKey(r)] = rp
+			}
+
+			rp.Done = date
+			if err := progress.save(); err != nil {
+				return err
+			}
+		}
+
+		rn.logger.Printf("INFO: Backfilled the reports for %s", date)
+	}
+
+	return rn.SaveCache()
+}
+
+// boundedReport returns a copy of the report which only finds the tickets
+// as they were by the end of the day, as the date ranges only limit how
+// long ago the tickets are from. The created date is always bounded, along
+// with any other date the ranges use.
+func boundedReport(r *conf.Report, day time.Time) *conf.Report {
+	before := "<" + day.AddDate(0, 0, 1).Format(dateFormat)
+
+	bounds := conf.DateFilters{{Custom: before}}
+	seen := map[string]bool{"": true, "created": true}
+
+	for _, d := range r.Filter.DateRange {
+		if !seen[string(d.Attribute)] {
+			seen[string(d.Attribute)] = true
+			bounds = append(bounds, conf.DateFilter{Attribute: d.Attribute, Custom: before})
+		}
+	}
+
+	bounded := *r
+	bounded.Filter.DateRange = append(append(conf.DateFilters{}, r.Filter.DateRange...), bounds...)
+
+	return &bounded
+}
@@ -0,0 +1,258 @@
+package zendesk
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk/zendesktest"
+)
+
+// interruptedSink fails the send numbered failAt, as if the backfill had
+// been interrupted then.
+type interruptedSink struct {
+	Sink
+	sends, failAt int
+}
+
+func (s *interruptedSink) Send(r *conf.Report, data *ReportData) error {
+	if s.sends++; s.sends == s.failAt {
+		return errors.New("Interrupted")
+	}
+
+	return s.Sink.Send(r, data)
+}
+
+func (s *interruptedSink) String() string {
+	return sinkName(s.Sink)
+}
+
+func TestBackfill(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	fake := zendesktest.New([]zendesktest.Ticket{
+		{ID: 1, Status: "open", Tags: []string{"beta"}, CreatedAt: day(25).Add(10 * time.Hour)},
+		{ID: 2, Status: "open", Tags: []string{"beta"}, CreatedAt: day(26).Add(10 * time.Hour)},
+		{ID: 3, Status: "open", Tags: []string{"vip"}, CreatedAt: day(27).Add(10 * time.Hour)},
+		{ID: 4, Status: "solved", Tags: []string{"beta"}, CreatedAt: day(27).Add(10 * time.Hour)},
+	})
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "zendesk_backfill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &conf.Config{Zendesk: conf.Zendesk{
+		Auth: conf.Auth{URL: server.URL, Email: "test@example.com", APIKey: "12345"},
+		Reports: []conf.Report{
+			{
+				Name:    TicketCountsReport,
+				DataSet: "beta.tickets",
+				Mode:    conf.AppendMode,
+				Filter:  conf.SearchFilter{Value: map[string]string{"tags:": "beta"}},
+			},
+			{
+				Name:              TicketCountsReport,
+				DataSet:           "beta.tickets",
+				GeckoboardAccount: "other",
+				Mode:              conf.AppendMode,
+				Filter:            conf.SearchFilter{Value: map[string]string{"tags:": "vip"}},
+			},
+			{Name: TicketCountsReport, DataSet: "all.tickets"},
+		},
+	}}
+
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	var out bytes.Buffer
+	var logger memoryLogger
+	progress := filepath.Join(dir, "progress.json")
+
+	sink := &interruptedSink{Sink: NewFileSink(&out), failAt: 3}
+	err = NewRunner(c, RunnerOptions{Logger: &logger, Sink: sink}).Backfill(day(25), day(27), progress)
+
+	expectedErr := "Backfilling report 'beta.tickets' for 2017-01-26 failed with: Interrupted"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error %q but got %v", expectedErr, err)
+	}
+
+	expectedLog := []string{
+		"WARN: Skipping report 'all.tickets' as only reports in append mode build up history",
+		"INFO: Backfilled the reports for 2017-01-25",
+	}
+
+	if !reflect.DeepEqual([]string(logger), expectedLog) {
+		t.Errorf("Expected log\n%q\nbut got\n%q", expectedLog, logger)
+	}
+
+	// Running again carries on from the day which failed, waiting when
+	// Zendesk rate limits the backfill.
+	fake.RateLimit(1, 30)
+
+	if err := NewRunner(c, RunnerOptions{Logger: &logger, Sink: NewFileSink(&out)}).Backfill(day(25), day(27), progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(waits, []time.Duration{30 * time.Second}) {
+		t.Errorf("Expected to wait 30s when rate limited but waited %v", waits)
+	}
+
+	// Each day only counts the tickets created by its end, and the report
+	// with the same dataset in another account keeps its own progress.
+	expected := strings.Join([]string{
+		`{"dataset":"beta.tickets","records":[{"date":"2017-01-25","grouped_by":"All","ticket_count":1}]}`,
+		`{"dataset":"beta.tickets","records":[{"date":"2017-01-25","grouped_by":"All","ticket_count":0}]}`,
+		`{"dataset":"beta.tickets","records":[{"date":"2017-01-26","grouped_by":"All","ticket_count":2}]}`,
+		`{"dataset":"beta.tickets","records":[{"date":"2017-01-26","grouped_by":"All","ticket_count":0}]}`,
+		`{"dataset":"beta.tickets","records":[{"date":"2017-01-27","grouped_by":"All","ticket_count":3}]}`,
+		`{"dataset":"beta.tickets","records":[{"date":"2017-01-27","grouped_by":"All","ticket_count":1}]}`,
+	}, "\n") + "\n"
+
+	if out.String() != expected {
+		t.Errorf("Expected the output\n%s\nbut got\n%s", expected, out.String())
+	}
+
+	// Once done running again has nothing left to backfill.
+	requests := len(fake.Requests())
+
+	if err := NewRunner(c, RunnerOptions{Logger: &logger, Sink: NewFileSink(&out)}).Backfill(day(25), day(27), progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Requests()) != requests || out.String() != expected {
+		t.Errorf("Expected the finished backfill not to run the reports again but got\n%s", out.String())
+	}
+}
+
+func TestBackfillErrors(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	dir, err := ioutil.TempDir("", "zendesk_backfill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	invalid := write("invalid.json", "{")
+	otherSink := write("other_sink.json", `{"sink":"a file","reports":{}}`)
+	started := write("started.json", `{"sink":"Geckoboard","reports":{"default/tickets":{"from":"2017-01-26","done":"2017-01-27"}}}`)
+	behind := write("behind.json", `{"sink":"Geckoboard","reports":{"default/tickets":{"from":"2017-01-10","done":"2017-01-20"}}}`)
+
+	appendReport := conf.Report{Name: TicketCountsReport, DataSet: "tickets", Mode: conf.AppendMode}
+	replaceReport := conf.Report{Name: TicketCountsReport, DataSet: "tickets"}
+
+	testCases := []struct {
+		report   conf.Report
+		first    time.Time
+		progress string
+		err      string
+	}{
+		{
+			report: appendReport,
+			first:  day(28),
+			err:    "The backfill's first day 2017-01-28 is after its last day 2017-01-27",
+		},
+		{
+			report:   appendReport,
+			first:    day(25),
+			progress: invalid,
+			err:      "Backfill progress " + invalid + " is not valid: unexpected end of JSON input",
+		},
+		{
+			report:   appendReport,
+			first:    day(25),
+			progress: otherSink,
+			err:      "Backfill progress " + otherSink + " is of sending the records to a file rather than Geckoboard, give another progress file",
+		},
+		{
+			report:   appendReport,
+			first:    day(25),
+			progress: started,
+			err:      "Backfill progress " + started + " has report 'tickets' starting on 2017-01-26, remove it to backfill from 2017-01-25",
+		},
+		{
+			report:   appendReport,
+			first:    day(25),
+			progress: behind,
+			err:      "Backfill progress " + behind + " has report 'tickets' done to 2017-01-20, backfill from 2017-01-21 or before to leave no gap",
+		},
+		{
+			report: replaceReport,
+			first:  day(25),
+			err:    "None of the reports are in append mode, which backfilling needs",
+		},
+	}
+
+	for i, tc := range testCases {
+		c := &conf.Config{Zendesk: conf.Zendesk{Reports: []conf.Report{tc.report}}}
+
+		progress := tc.progress
+		if progress == "" {
+			progress = filepath.Join(dir, "progress.json")
+		}
+
+		var logger memoryLogger
+		err := NewRunner(c, RunnerOptions{Logger: &logger}).Backfill(tc.first, day(27), progress)
+
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+	}
+}
+
+func TestBoundedReport(t *testing.T) {
+	day := time.Date(2017, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		filter conf.SearchFilter
+		query  string
+	}{
+		{
+			filter: conf.SearchFilter{Value: map[string]string{"status:": "open"}},
+			query:  "type:ticket created<2017-02-01 status:open",
+		},
+		{
+			filter: conf.SearchFilter{DateRange: conf.DateFilters{{Unit: "day", Past: 7}}},
+			query:  "type:ticket created>=2017-01-24 created<2017-02-01",
+		},
+		{
+			filter: conf.SearchFilter{DateRange: conf.DateFilters{{Attribute: "solved", Unit: "day", Past: 7}}},
+			query:  "type:ticket solved>=2017-01-24 created<2017-02-01 solved<2017-02-01",
+		},
+	}
+
+	for i, tc := range testCases {
+		r := conf.Report{Filter: tc.filter}
+
+		if query := boundedReport(&r, day).Filter.BuildQuery(&day); query != tc.query {
+			t.Errorf("[spec %d] Expected query %q but got %q", i, tc.query, query)
+		}
+
+		if len(r.Filter.DateRange) != len(tc.filter.DateRange) {
+			t.Errorf("[spec %d] Expected the report's date ranges to be left as they were", i)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package zendesk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+const defaultCacheTTL = 5 * time.Minute
+
+// cache holds the pages of search results, keyed by the account and their
+// URL, and the tickets with their metric sets, keyed by the account and
+// ticket ID, so the reports of a run searching for the same tickets only
+// fetch them once. A nil cache fetches everything each time.
+type cache struct {
+	ttl  time.Duration
+	path string
+	now  func() time.Time
+
+	mu       sync.Mutex
+	searches map[string]cachedSearch
+	tickets  map[string]cachedTicket
+	stats    cacheStats
+}
+
+type cachedSearch struct {
+	Page      TicketPayload `json:"page"`
+	FetchedAt time.Time     `json:"fetched_at"`
+}
+
+type cachedTicket struct {
+	Ticket    Ticket    `json:"ticket"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// cacheFile is the cache as it is kept on disk between runs.
+type cacheFile struct {
+	Searches map[string]cachedSearch `json:"searches"`
+	Tickets  map[string]cachedTicket `json:"tickets"`
+}
+
+// cacheStats counts the searches and tickets found in the cache, hits, and
+// those which had to be fetched, misses.
+type cacheStats struct {
+	SearchHits, SearchMisses int
+	TicketHits, TicketMisses int
+}
+
+func (s cacheStats) String() string {
+	rate := func(hits, misses int) string {
+		if hits+misses == 0 {
+			return "0 of 0"
+		}
+
+		return fmt.Sprintf("%d of %d (%d%%)", hits, hits+misses, hits*100/(hits+misses))
+	}
+
+	return fmt.Sprintf("search pages %s, ticket metric sets %s",
+		rate(s.SearchHits, s.SearchMisses), rate(s.TicketHits, s.TicketMisses))
+}
+
+// newCache returns the cache for a run with the options, which is nil when
+// it is disabled. When the options have a path the entries kept there by
+// earlier runs are loaded, a missing file being an empty cache.
+func newCache(opts conf.Cache) (*cache, error) {
+	if opts.Disabled {
+		return nil, nil
+	}
+
+	c := &cache{
+		ttl:      opts.TTL,
+		path:     opts.Path,
+		now:      time.Now,
+		searches: map[string]cachedSearch{},
+		tickets:  map[string]cachedTicket{},
+	}
+
+	if c.ttl == 0 {
+		c.ttl = defaultCacheTTL
+	}
+
+	if c.path == "" {
+		return c, nil
+	}
+
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+
+	if err != nil {
+		return c, fmt.Errorf("Reading the Zendesk cache failed with: %s", err.Error())
+	}
+
+	var f cacheFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return c, fmt.Errorf("Zendesk cache %s is not valid: %s", c.path, err.Error())
+	}
+
+	// Expired entries are skipped when looked up and left out when saving.
+	if f.Searches != nil {
+		c.searches = f.Searches
+	}
+
+	if f.Tickets != nil {
+		c.tickets = f.Tickets
+	}
+
+	return c, nil
+}
+
+func (c *cache) fresh(fetchedAt time.Time) bool {
+	return c.now().Sub(fetchedAt) < c.ttl
+}
+
+// search returns the cached page of search results with the key.
+func (c *cache) search(key string) (*TicketPayload, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.searches[key]
+	if !ok || !c.fresh(s.FetchedAt) {
+		c.stats.SearchMisses++
+		return nil, false
+	}
+
+	c.stats.SearchHits++
+
+	page := s.Page
+	return &page, true
+}
+
+func (c *cache) addSearch(key string, page *TicketPayload) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.searches[key] = cachedSearch{Page: *page, FetchedAt: c.now()}
+}
+
+// ticket returns the cached ticket with its metric set from the account.
+func (c *cache) ticket(account string, id int) (Ticket, bool) {
+	if c == nil {
+		return Ticket{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.tickets[ticketKey(account, id)]
+	if !ok || !c.fresh(t.FetchedAt) {
+		c.stats.TicketMisses++
+		return Ticket{}, false
+	}
+
+	c.stats.TicketHits++
+	return t.Ticket, true
+}
+
+func (c *cache) addTickets(account string, tickets []Ticket) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range tickets {
+		c.tickets[ticketKey(account, t.ID)] = cachedTicket{Ticket: t, FetchedAt: c.now()}
+	}
+}
+
+// save writes the unexpired entries to the cache's file, if it has one.
+func (c *cache) save() error {
+	if c == nil || c.path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := cacheFile{Searches: map[string]cachedSearch{}, Tickets: map[string]cachedTicket{}}
+
+	for k, s := range c.searches {
+		if c.fresh(s.FetchedAt) {
+			f.Searches[k] = s
+		}
+	}
+
+	for k, t := range c.tickets {
+		if c.fresh(t.FetchedAt) {
+			f.Tickets[k] = t
+		}
+	}
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	// The cache holds ticket data so only the user can read it.
+	if err := ioutil.WriteFile(c.path, b, 0600); err != nil {
+		return fmt.Errorf("Saving the Zendesk cache failed with: %s", err.Error())
+	}
+
+	return nil
+}
+
+// statistics returns the hits and misses of the cache so far.
+func (c *cache) statistics() cacheStats {
+	if c == nil {
+		return cacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+func ticketKey(account string, id int) string {
+	return account + "#" + strconv.Itoa(id)
+}
@@ -0,0 +1,199 @@
+package zendesk
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk/zendesktest"
+)
+
+func TestClientUsesCache(t *testing.T) {
+	fake := zendesktest.New([]zendesktest.Ticket{
+		{ID: 1, Status: "open", Tags: []string{"beta"}, Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 30}}},
+		{ID: 2, Status: "solved", Tags: []string{"beta"}, Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 45}}},
+		{ID: 3, Status: "open", Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 90}}},
+	})
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	rc, err := newCache(conf.Cache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := newClient(&conf.Auth{URL: server.URL, Email: "test@example.com", APIKey: "12345"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.cache = rc
+
+	// Another user of the same account can see other tickets so doesn't
+	// share the cached results.
+	other, err := newClient(&conf.Auth{URL: server.URL, Email: "other@example.com", APIKey: "67890"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other.cache = rc
+
+	searches := []func() error{
+		func() error { _, err := c.TicketMetrics(&Query{Params: "type:ticket tags:beta"}); return err },
+		func() error { _, err := c.SearchTickets(&Query{Params: "type:ticket tags:beta"}); return err },
+		func() error { _, err := c.TicketMetrics(&Query{Params: "type:ticket status:open"}); return err },
+		func() error { _, err := other.SearchTickets(&Query{Params: "type:ticket tags:beta"}); return err },
+	}
+
+	for _, search := range searches {
+		if err := search(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"/api/v2/search.json?query=type%3Aticket+tags%3Abeta",
+		"/api/v2/tickets/show_many.json?ids=1%2C2&include=metric_sets",
+		"/api/v2/search.json?query=type%3Aticket+status%3Aopen",
+		"/api/v2/tickets/show_many.json?ids=3&include=metric_sets",
+		"/api/v2/search.json?query=type%3Aticket+tags%3Abeta",
+	}
+
+	if requests := fake.Requests(); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Expected requests %v but got %v", expected, requests)
+	}
+
+	tm, err := c.TicketMetrics(&Query{Params: "type:ticket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var replyTimes []int
+	for _, ticket := range tm.Tickets {
+		replyTimes = append(replyTimes, *ticket.Metrics.ReplyTime.Calendar)
+	}
+
+	if !reflect.DeepEqual(replyTimes, []int{30, 45, 90}) || tm.Count != 3 {
+		t.Errorf("Expected the cached reply times [30 45 90] but got %v", replyTimes)
+	}
+
+	expectedStats := cacheStats{SearchHits: 1, SearchMisses: 4, TicketHits: 4, TicketMisses: 3}
+	if stats := rc.statistics(); stats != expectedStats {
+		t.Errorf("Expected cache statistics %+v but got %+v", expectedStats, stats)
+	}
+}
+
+func TestCacheExpiresAndPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zendesk_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2017, 2, 1, 9, 0, 0, 0, time.UTC)
+	path := filepath.Join(dir, "cache.json")
+
+	rc, err := newCache(conf.Cache{Path: path, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc.now = func() time.Time { return now }
+	rc.addSearch("search", &TicketPayload{Count: 2})
+	rc.addTickets("account", []Ticket{{ID: 1}, {ID: 2}})
+
+	now = now.Add(30 * time.Minute)
+	rc.addTickets("account", []Ticket{{ID: 3}})
+
+	if err := rc.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(45 * time.Minute)
+
+	loaded, err := newCache(conf.Cache{Path: path, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded.now = func() time.Time { return now }
+
+	if _, ok := loaded.search("search"); ok {
+		t.Error("Expected the search cached over an hour ago to have expired")
+	}
+
+	if _, ok := loaded.ticket("account", 1); ok {
+		t.Error("Expected ticket 1 cached over an hour ago to have expired")
+	}
+
+	if ticket, ok := loaded.ticket("account", 3); !ok || ticket.ID != 3 {
+		t.Errorf("Expected ticket 3 to have been kept in the cache file but got %v", ticket)
+	}
+
+	if _, ok := loaded.ticket("other", 3); ok {
+		t.Error("Expected tickets of other accounts not to be cached")
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zendesk_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(invalid, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		opts conf.Cache
+		ttl  time.Duration
+		err  string
+	}{
+		{
+			opts: conf.Cache{},
+			ttl:  defaultCacheTTL,
+		},
+		{
+			opts: conf.Cache{TTL: time.Hour, Path: filepath.Join(dir, "missing.json")},
+			ttl:  time.Hour,
+		},
+		{
+			opts: conf.Cache{Path: invalid},
+			ttl:  defaultCacheTTL,
+			err:  "Zendesk cache " + invalid + " is not valid: unexpected end of JSON input",
+		},
+	}
+
+	for i, tc := range testCases {
+		rc, err := newCache(tc.opts)
+
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+
+		// An unreadable cache file still gives an empty cache for the run.
+		if rc == nil || rc.ttl != tc.ttl {
+			t.Errorf("[spec %d] Expected a cache with ttl %s but got %+v", i, tc.ttl, rc)
+		}
+	}
+
+	rc, err := newCache(conf.Cache{Disabled: true})
+	if rc != nil || err != nil {
+		t.Errorf("Expected no cache when it is disabled but got %+v and %v", rc, err)
+	}
+
+	// A disabled cache, being nil, never has anything cached.
+	rc.addSearch("search", &TicketPayload{})
+	if _, ok := rc.search("search"); ok {
+		t.Error("Expected a disabled cache not to cache searches")
+	}
+}
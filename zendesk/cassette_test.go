@@ -0,0 +1,67 @@
+package zendesk
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/cassette"
+)
+
+var updateCassettes = flag.Bool("update-cassettes", false, "Record the cassettes in testdata again against the fake Zendesk and Geckoboard")
+
+// TestHandleReportsCassette replays the requests of the end to end config's
+// reports, failing when the reports make different requests, such as
+// sending different data to Geckoboard. When the change is expected record
+// the cassette again with go test ./zendesk -run Cassette -update-cassettes
+func TestHandleReportsCassette(t *testing.T) {
+	path := filepath.Join("testdata", "reports.cassette.json")
+	secrets := []string{"Ap1K4y", "M4rk3t1ng", "test@example.com", "12345", "0auth70k3n"}
+
+	// The hosts aren't recorded so replaying needs none of them to exist.
+	urls := []string{"http://geckoboard.invalid", "http://marketing.geckoboard.invalid", "http://zendesk.invalid", "http://brand.zendesk.invalid"}
+
+	var c *cassette.Cassette
+	if *updateCassettes {
+		fakes := newEndToEndFakes()
+		defer fakes.close()
+
+		urls = fakes.urls()
+		c = cassette.New(path, secrets...)
+	} else {
+		var err error
+		if c, err = cassette.Load(path, secrets...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := loadEndToEndConfig(t, urls)
+	transport := c.Transport(http.DefaultTransport)
+
+	NewRunner(config, RunnerOptions{
+		HTTPClient: &http.Client{Transport: transport},
+		Clock:      AsOf(time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)),
+		Sink:       NewGeckoboardSink(config, transport, log.Default()),
+	}).Run()
+
+	if *updateCassettes {
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	if unmatched := c.Unmatched(); len(unmatched) > 0 {
+		t.Errorf("The reports made requests which weren't recorded, if this is expected run the test "+
+			"again with -update-cassettes:\n  %s", strings.Join(unmatched, "\n  "))
+	}
+
+	if unused := c.Unused(); len(unused) > 0 {
+		t.Errorf("The reports didn't make some of the recorded requests:\n  %s", strings.Join(unused, "\n  "))
+	}
+}
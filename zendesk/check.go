@@ -0,0 +1,92 @@
+package zendesk
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+)
+
+// CheckZendesk returns an error if Zendesk doesn't accept the credentials
+// of each Zendesk account used by the reports in the config. The requests
+// are made with the HTTP client, or one using each account's timeout, proxy
+// and CA certificates when it is nil.
+func CheckZendesk(c *conf.Config, httpClient *http.Client) error {
+	return checkAccounts(c.UsedZendeskAccounts(), "Zendesk", func(name string) error {
+		auths, err := c.ZendeskAuths(&conf.Report{ZendeskAccounts: []string{name}})
+		if err != nil {
+			return err
+		}
+
+		client, err := NewClient(auths[0], httpClient)
+		if err != nil {
+			return err
+		}
+
+		client.PaginateResults = false
+		return client.CheckAuth()
+	})
+}
+
+// CheckGeckoboard returns an error if Geckoboard doesn't accept the API key
+// of each Geckoboard account used by the reports in the config, sending the
+// requests with the transport, or http.DefaultTransport when it is nil.
+func CheckGeckoboard(c *conf.Config, transport http.RoundTripper) error {
+	return checkAccounts(c.UsedGeckoboardAccounts(), "Geckoboard", func(name string) error {
+		account, err := c.GeckoboardFor(&conf.Report{GeckoboardAccount: name})
+		if err != nil {
+			return err
+		}
+
+		return NewGeckoboardClient(account, transport).Ping()
+	})
+}
+
+// checkAccounts checks each of the named accounts, or the default account
+// when there are none. The account is only named in the error when it
+// isn't the default account.
+func checkAccounts(names []string, service string, check func(name string) error) error {
+	if len(names) == 0 {
+		names = []string{conf.DefaultAccount}
+	}
+
+	for _, n := range names {
+		err := check(n)
+		if err != nil && n != conf.DefaultAccount {
+			return fmt.Errorf("%s account '%s': %s", service, n, err.Error())
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// geckoboardMaxRetries returns the Geckoboard client's MaxRetries for the
+// config's max_retries, which turns retrying off when set to 0.
+func geckoboardMaxRetries(c *conf.Geckoboard) int {
+	switch {
+	case c.MaxRetries == nil:
+		return 0
+	case *c.MaxRetries == 0:
+		return gb.NoRetries
+	}
+
+	return *c.MaxRetries
+}
+
+// NewGeckoboardClient returns a Geckoboard client using the API key and
+// connection options in the config, sending the requests with the
+// transport, or http.DefaultTransport when it is nil.
+func NewGeckoboardClient(c *conf.Geckoboard, transport http.RoundTripper) *gb.Client {
+	return gb.New(gb.Config{
+		Key:        c.APIKey,
+		URL:        c.URL,
+		Timeout:    c.Timeout,
+		MaxRetries: geckoboardMaxRetries(c),
+		Transport:  transport,
+	})
+}
@@ -0,0 +1,112 @@
+package zendesk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+)
+
+func TestCheckZendesk(t *testing.T) {
+	testCases := []struct {
+		auth   conf.Auth
+		status int
+		body   string
+		err    string
+	}{
+		{
+			status: http.StatusOK,
+			body:   `{"user": {"id": 123, "email": "test@example.com"}}`,
+		},
+		{
+			status: http.StatusOK,
+			body:   `{"user": {"id": null, "name": "Anonymous user"}}`,
+			err:    "Zendesk didn't accept the credentials, check the email, password, api_key or oauth_token and subdomain",
+		},
+		{
+			status: http.StatusUnauthorized,
+			body:   `{"error": "Couldn't authenticate you"}`,
+			err:    "Zendesk request failed with status 401: Couldn't authenticate you",
+		},
+		{
+			status: http.StatusForbidden,
+			body:   `{"error": "Forbidden", "description": "You do not have access to this page."}`,
+			err:    "Zendesk request failed with status 403: You do not have access to this page.",
+		},
+		{
+			auth:   conf.Auth{OAuthToken: "0auth70k3n"},
+			status: http.StatusForbidden,
+			body:   `{"error": "Forbidden", "description": "You do not have access to this page."}`,
+			err:    "Zendesk request failed with status 403: You do not have access to this page, check the OAuth token has the read scope",
+		},
+		{
+			status: http.StatusNotFound,
+			body:   `{"error": {"title": "No help desk at test.zendesk.com", "message": "There is no help desk here"}}`,
+			err:    "Zendesk request failed with status 404: There is no help desk here",
+		},
+		{
+			status: http.StatusBadGateway,
+			body:   `<html>Bad Gateway</html>`,
+			err:    "Zendesk request failed with status 502: Bad Gateway",
+		},
+	}
+
+	for i, tc := range testCases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v2/users/me.json" {
+				t.Errorf("Unexpected url recieved %s", r.URL)
+			}
+
+			w.WriteHeader(tc.status)
+			fmt.Fprint(w, tc.body)
+		}))
+
+		tc.auth.URL = server.URL
+
+		err := CheckZendesk(&conf.Config{Zendesk: conf.Zendesk{Auth: tc.auth}}, nil)
+		server.Close()
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+	}
+}
+
+func TestCheckGeckoboard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"Your API key is invalid"}}`)
+	}))
+	defer server.Close()
+
+	err := CheckGeckoboard(&conf.Config{Geckoboard: conf.Geckoboard{URL: server.URL}}, nil)
+	if err == nil || err.Error() != "Your API key is invalid (status 401)" {
+		t.Errorf("Expected invalid API key error, got %v", err)
+	}
+}
+
+func TestGeckoboardMaxRetries(t *testing.T) {
+	retries := func(n int) *int { return &n }
+
+	testCases := []struct {
+		in  *int
+		out int
+	}{
+		{in: nil, out: 0},
+		{in: retries(0), out: gb.NoRetries},
+		{in: retries(5), out: 5},
+	}
+
+	for i, tc := range testCases {
+		if out := geckoboardMaxRetries(&conf.Geckoboard{MaxRetries: tc.in}); out != tc.out {
+			t.Errorf("[spec %d] Expected %d but got %d", i, tc.out, out)
+		}
+	}
+}
@@ -2,12 +2,19 @@ package zendesk
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/geckoboard/zendesk_dataset/conf"
@@ -19,6 +26,17 @@ var splitTicketCount = 98
 type Client struct {
 	Auth            conf.Auth
 	PaginateResults bool
+
+	httpClient *http.Client
+	cache      *cache
+
+	// rateLimitRetries is how many times a rate limited request is
+	// retried, after waiting as long as Zendesk asks.
+	rateLimitRetries int
+
+	// logger logs the retries, the standard logger unless the client
+	// belongs to a runner given another.
+	logger Logger
 }
 
 // Query holds the params and endpoint for which the buildURL method uses.
@@ -32,19 +50,112 @@ const (
 	basePath    = "/api/v2"
 	searchPath  = "/search.json"
 	ticketsPath = "/tickets/show_many.json"
+	mePath      = "/users/me.json"
 )
 
-var (
-	scheme  = "https"
-	host    = "%s.zendesk.com"
-	httpClt = &http.Client{Timeout: time.Second * 10}
-)
+const defaultTimeout = 10 * time.Second
+
+func newClient(auth *conf.Auth, paginateResults bool) (*Client, error) {
+	httpClient, err := newHTTPClient(auth)
+	if err != nil {
+		return nil, err
+	}
 
-func newClient(auth *conf.Auth, paginateResults bool) *Client {
 	return &Client{
 		Auth:            *auth,
 		PaginateResults: paginateResults,
+		httpClient:      httpClient,
+		logger:          log.Default(),
+	}, nil
+}
+
+// NewClient returns a client for the Zendesk account which paginates the
+// results, making its requests with the HTTP client, or one using the
+// auth's timeout, proxy and CA certificates when it is nil.
+func NewClient(auth conf.Auth, httpClient *http.Client) (*Client, error) {
+	if httpClient == nil {
+		return newClient(&auth, true)
+	}
+
+	return &Client{Auth: auth, PaginateResults: true, httpClient: httpClient, logger: log.Default()}, nil
+}
+
+// newHTTPClient returns an HTTP client using the timeout, proxy and CA
+// certificates of the auth options.
+func newHTTPClient(auth *conf.Auth) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if auth.Proxy != "" {
+		proxy, err := url.Parse(auth.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Zendesk proxy '%s' is not a valid URL: %s", auth.Proxy, err.Error())
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if auth.CAFile != "" {
+		pem, err := ioutil.ReadFile(auth.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Reading the Zendesk ca_file failed with: %s", err.Error())
+		}
+
+		// Trust the certificates as well as the system's rather than in place
+		// of them, falling back to only them where the system's can't be read.
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Zendesk ca_file %s has no PEM certificates", auth.CAFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
 	}
+
+	timeout := auth.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// baseURL returns the URL of the Zendesk account, which is the subdomain of
+// zendesk.com unless the auth has its own URL.
+func (c *Client) baseURL() (*url.URL, error) {
+	if c.Auth.URL == "" {
+		return &url.URL{Scheme: "https", Host: c.Auth.Subdomain + ".zendesk.com"}, nil
+	}
+
+	u, err := url.Parse(c.Auth.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Zendesk url '%s' is not a valid URL: %s", c.Auth.URL, err.Error())
+	}
+
+	return u, nil
+}
+
+// account returns the subdomain or URL identifying the Zendesk account.
+func (c *Client) account() string {
+	return accountName(&c.Auth)
+}
+
+func accountName(auth *conf.Auth) string {
+	if auth.URL != "" {
+		return auth.URL
+	}
+
+	return auth.Subdomain
+}
+
+// cacheKey identifies the account and the credentials used in the keys of
+// the cache, as different users can see different tickets, without keeping
+// the credentials themselves.
+func (c *Client) cacheKey() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{c.Auth.Email, c.Auth.APIKey, c.Auth.Password, c.Auth.OAuthToken}, "\x00")))
+	return c.account() + "#" + hex.EncodeToString(sum[:4])
 }
 
 func (c *Client) buildURL(qy *Query) (string, error) {
@@ -52,14 +163,14 @@ func (c *Client) buildURL(qy *Query) (string, error) {
 		return "", errors.New("Endpoint is required to build url")
 	}
 
-	u := url.URL{
-		Scheme: scheme,
-		Host:   fmt.Sprintf(host, c.Auth.Subdomain),
-		Path:   basePath + qy.Endpoint,
+	u, err := c.baseURL()
+	if err != nil {
+		return "", err
 	}
 
+	u.Path = strings.TrimSuffix(u.Path, "/") + basePath + qy.Endpoint
+
 	q := url.Values{}
-	var err error
 	if qy.Params != "" {
 		q, err = url.ParseQuery("query=" + qy.Params)
 		if err != nil {
@@ -86,9 +197,12 @@ func (c *Client) buildRequest(method, fullURL string) (*http.Request, error) {
 		return nil, err
 	}
 
-	if c.Auth.Password != "" {
+	switch {
+	case c.Auth.OAuthToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.Auth.OAuthToken)
+	case c.Auth.Password != "":
 		req.SetBasicAuth(c.Auth.Email, c.Auth.Password)
-	} else {
+	default:
 		req.SetBasicAuth(c.Auth.Email+"/token", c.Auth.APIKey)
 	}
 
@@ -109,20 +223,7 @@ func (c *Client) SearchTickets(q *Query) (*TicketPayload, error) {
 	}
 
 	for url != "" {
-		req, err := c.buildRequest("GET", url)
-		if err != nil {
-			return nil, err
-		}
-
-		resp, err := httpClt.Do(req)
-		if err != nil {
-			return nil, err
-		}
-
-		defer resp.Body.Close()
-
-		var tp TicketPayload
-		err = json.NewDecoder(resp.Body).Decode(&tp)
+		tp, err := c.searchPage(url)
 		if err != nil {
 			return nil, err
 		}
@@ -131,13 +232,48 @@ func (c *Client) SearchTickets(q *Query) (*TicketPayload, error) {
 			url = tp.NextPage
 			t = append(t, tp.Tickets...)
 		} else {
-			return &tp, nil
+			return tp, nil
 		}
 	}
 
 	return &TicketPayload{Count: len(t), Tickets: t}, nil
 }
 
+// CountTickets returns the count of tickets the query finds from the first
+// page of the search results, without paginating through the tickets.
+func (c *Client) CountTickets(q *Query) (int, error) {
+	first := *c
+	first.PaginateResults = false
+
+	tp, err := first.SearchTickets(q)
+	if err != nil {
+		return 0, err
+	}
+
+	return tp.Count, nil
+}
+
+// searchPage returns the page of search results at the URL, from the cache
+// when it has been fetched already.
+func (c *Client) searchPage(url string) (*TicketPayload, error) {
+	if tp, ok := c.cache.search(c.cacheKey() + " " + url); ok {
+		return tp, nil
+	}
+
+	req, err := c.buildRequest("GET", url)
+	if err != nil {
+		return nil, err
+	}
+
+	var tp TicketPayload
+	if err := c.doRequest(req, &tp); err != nil {
+		return nil, err
+	}
+
+	c.cache.addSearch(c.cacheKey()+" "+url, &tp)
+	return &tp, nil
+}
+
 // TicketMetrics takes a query and returns TicketMetrics or an error if it
 // occurs. The ticket metrics utilises two endpoints first it uses SearchTickets
 // to get all the ticket IDs and then makes a request on the tickets/show_many.json
@@ -150,14 +286,26 @@ func (c *Client) TicketMetrics(q *Query) (*TicketMetrics, error) {
 		return nil, err
 	}
 
+	// Only fetch the metric sets of the tickets not already cached.
+	cached := map[int]Ticket{}
+	var missing []Ticket
+
+	for _, t := range tp.Tickets {
+		if ct, ok := c.cache.ticket(c.cacheKey(), t.ID); ok {
+			cached[t.ID] = ct
+		} else {
+			missing = append(missing, t)
+		}
+	}
+
 	//Extract all the ticket ids
 	var bf bytes.Buffer
-	var tickets []Ticket
+	var fetched []Ticket
 
-	for i, t := range tp.Tickets {
+	for i, t := range missing {
 		bf.WriteString(strconv.Itoa(t.ID))
 
-		if (i != 0 && i%splitTicketCount == 0) || i == len(tp.Tickets)-1 {
+		if (i != 0 && i%splitTicketCount == 0) || i == len(missing)-1 {
 			qy := &Query{
 				Endpoint: ticketsPath,
 				ExtraParams: map[string]string{
@@ -177,26 +325,106 @@ func (c *Client) TicketMetrics(q *Query) (*TicketMetrics, error) {
 				return nil, err
 			}
 
-			resp, err := httpClt.Do(req)
-			if err != nil {
-				return nil, err
-			}
-
-			defer resp.Body.Close()
-
 			var tm TicketMetrics
-			err = json.NewDecoder(resp.Body).Decode(&tm)
-
-			if err != nil {
+			if err := c.doRequest(req, &tm); err != nil {
 				return nil, err
 			}
 
-			tickets = append(tickets, tm.Tickets...)
+			fetched = append(fetched, tm.Tickets...)
 			bf.Reset()
 		} else {
 			bf.WriteString(",")
 		}
 	}
 
+	c.cache.addTickets(c.cacheKey(), fetched)
+
+	var tickets []Ticket
+	for _, t := range tp.Tickets {
+		if ct, ok := cached[t.ID]; ok {
+			tickets = append(tickets, ct)
+		}
+	}
+
+	tickets = append(tickets, fetched...)
 	return &TicketMetrics{Count: len(tickets), Tickets: tickets}, nil
 }
+
+// CheckAuth checks Zendesk accepts the credentials by requesting the
+// authenticated user. Zendesk responds with an anonymous user without an ID
+// rather than an error for some invalid credentials so both are checked.
+func (c *Client) CheckAuth() error {
+	url, err := c.buildURL(&Query{Endpoint: mePath})
+	if err != nil {
+		return err
+	}
+
+	req, err := c.buildRequest("GET", url)
+	if err != nil {
+		return err
+	}
+
+	var me struct {
+		User struct {
+			ID int `json:"id"`
+		} `json:"user"`
+	}
+
+	if err := c.doRequest(req, &me); err != nil {
+		return err
+	}
+
+	if me.User.ID == 0 {
+		return errors.New("Zendesk didn't accept the credentials, check the email, password, api_key or oauth_token and subdomain")
+	}
+
+	return nil
+}
+
+// sleep is used to wait between retries and is replaced in tests.
+var sleep = time.Sleep
+
+// doRequest sends the request and decodes the response into out, returning
+// an Error if Zendesk responds with an unsuccessful status code. Rate
+// limited requests are retried as many times as the client allows.
+func (c *Client) doRequest(req *http.Request, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		err := c.sendRequest(req, out)
+
+		e, ok := err.(Error)
+		if !ok || e.StatusCode != http.StatusTooManyRequests || attempt >= c.rateLimitRetries {
+			return err
+		}
+
+		wait := e.RetryAfter
+		if wait <= 0 {
+			wait = time.Minute
+		}
+
+		c.logger.Printf("WARN: Zendesk rate limited the request, retrying in %s", wait)
+		sleep(wait)
+	}
+}
+
+func (c *Client) sendRequest(req *http.Request, out interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := newError(resp)
+
+		// Zendesk forbids requests with an OAuth token missing the scope
+		// they need, which for all the requests made here is read.
+		if e.StatusCode == http.StatusForbidden && c.Auth.OAuthToken != "" {
+			e.Message = strings.TrimSuffix(e.Message, ".") + ", check the OAuth token has the read scope"
+		}
+
+		return e
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
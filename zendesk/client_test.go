@@ -1,14 +1,19 @@
 package zendesk
 
 import (
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
-	"strings"
 	"testing"
+	"time"
 
 	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk/zendesktest"
 )
 
 type TestCase struct {
@@ -62,6 +67,19 @@ func TestBuildRequest(t *testing.T) {
 				"AuthHeader": "Basic dGVzdEBleGFtcGxlLmNvbTo5ODc2Y2Jh",
 			},
 		},
+		{
+			Config: conf.Auth{
+				Subdomain:  "testdomain",
+				OAuthToken: "0auth70k3n",
+			},
+			Method:  "GET",
+			FullURL: "https://testdomain.zendesk.com/api/v2/search.json",
+			Expected: map[string]string{
+				"Method":     "GET",
+				"FullPath":   "https://testdomain.zendesk.com/api/v2/search.json",
+				"AuthHeader": "Bearer 0auth70k3n",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -87,7 +105,7 @@ func TestBuildRequest(t *testing.T) {
 		actAuth := req.Header.Get("Authorization")
 
 		if actAuth != tc.Expected["AuthHeader"] {
-			t.Errorf("Expected basic auth header %s, but got %s", tc.Expected["AuthHeader"], actAuth)
+			t.Errorf("Expected auth header %s, but got %s", tc.Expected["AuthHeader"], actAuth)
 		}
 	}
 }
@@ -166,18 +184,11 @@ func TestSearchTickets(t *testing.T) {
 		server := buildServerWithExpectations(&tc, t)
 		defer server.Close()
 
-		//Reset the scheme and host back to original so not to break other tests
-		defer func(h, s string) { host = h; scheme = s }(host, scheme)
-
-		scheme = "http"
-		host = "%s" + strings.Replace(server.URL, "http://", "", 1)
 		serverURL = server.URL
 
-		clt := Client{
-			Auth: conf.Auth{
-				Subdomain: "",
-			},
-			PaginateResults: tc.PaginateResults,
+		clt, err := newClient(&conf.Auth{URL: server.URL}, tc.PaginateResults)
+		if err != nil {
+			t.Fatal(err)
 		}
 
 		tp, err := clt.SearchTickets(&Query{Params: tc.Query})
@@ -232,18 +243,18 @@ func TestTicketMetrics(t *testing.T) {
 					{
 						Metrics: MetricSet{
 							ReplyTime: SubTimeMetric{
-								Calendar: 123,
+								Calendar: minutes(123),
 							},
 							FullResolutionTime: SubTimeMetric{
-								Business: 120,
-								Calendar: 100,
+								Business: minutes(120),
+								Calendar: minutes(100),
 							},
 						},
 					},
 					{
 						Metrics: MetricSet{
 							ReplyTime: SubTimeMetric{
-								Calendar: 103,
+								Calendar: minutes(103),
 							},
 						},
 					},
@@ -256,18 +267,11 @@ func TestTicketMetrics(t *testing.T) {
 		server := buildServerWithExpectations(&tc, t)
 		defer server.Close()
 
-		//Reset the scheme and host back to original so not to break other tests
-		defer func(h, s string) { host = h; scheme = s }(host, scheme)
-
-		scheme = "http"
-		host = "%s" + strings.Replace(server.URL, "http://", "", 1)
 		serverURL = server.URL
 
-		clt := Client{
-			Auth: conf.Auth{
-				Subdomain: "",
-			},
-			PaginateResults: tc.PaginateResults,
+		clt, err := newClient(&conf.Auth{URL: server.URL}, tc.PaginateResults)
+		if err != nil {
+			t.Fatal(err)
 		}
 
 		tp, err := clt.TicketMetrics(&Query{Params: tc.Query})
@@ -358,3 +362,153 @@ func buildServerWithExpectations(s *STTestCase, t *testing.T) *httptest.Server {
 
 	return server
 }
+
+func TestBuildURLWithBaseURL(t *testing.T) {
+	testCases := []struct {
+		url string
+		out string
+	}{
+		{
+			url: "https://support.example.com",
+			out: "https://support.example.com/api/v2/search.json?query=status%3Aopen",
+		},
+		{
+			url: "http://localhost:8080/zendesk/",
+			out: "http://localhost:8080/zendesk/api/v2/search.json?query=status%3Aopen",
+		},
+	}
+
+	for i, tc := range testCases {
+		c := Client{Auth: conf.Auth{Subdomain: "test", URL: tc.url}}
+
+		out, err := c.buildURL(&Query{Endpoint: searchPath, Params: "status:open"})
+		if err != nil {
+			t.Fatalf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if out != tc.out {
+			t.Errorf("[spec %d] Expected url %s but got %s", i, tc.out, out)
+		}
+	}
+}
+
+func TestClientCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"user": {"id": 123}}`)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "zendesk_dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := newClient(&conf.Auth{URL: server.URL}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.CheckAuth(); err == nil {
+		t.Error("Expected an error for the untrusted certificate but got none")
+	}
+
+	c, err = newClient(&conf.Auth{URL: server.URL, CAFile: caFile}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.CheckAuth(); err != nil {
+		t.Errorf("Unexpected error got %s", err)
+	}
+
+	if err := ioutil.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Zendesk ca_file " + caFile + " has no PEM certificates"
+	if _, err := newClient(&conf.Auth{CAFile: caFile}, false); err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q but got %v", expected, err)
+	}
+}
+
+func TestClientProxyAndTimeout(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		fmt.Fprint(w, `{"user": {"id": 123}}`)
+	}))
+	defer proxy.Close()
+
+	c, err := newClient(&conf.Auth{URL: "http://support.example.com", Proxy: proxy.URL, Timeout: time.Minute}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.CheckAuth(); err != nil {
+		t.Fatalf("Unexpected error got %s", err)
+	}
+
+	if proxied != "http://support.example.com/api/v2/users/me.json" {
+		t.Errorf("Expected the request to be sent through the proxy but it got %q", proxied)
+	}
+
+	if c.httpClient.Timeout != time.Minute {
+		t.Errorf("Expected timeout %s but got %s", time.Minute, c.httpClient.Timeout)
+	}
+
+	c, err = newClient(&conf.Auth{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.httpClient.Timeout != defaultTimeout {
+		t.Errorf("Expected the default timeout %s but got %s", defaultTimeout, c.httpClient.Timeout)
+	}
+}
+
+func TestClientWithFakeZendesk(t *testing.T) {
+	fake := zendesktest.New([]zendesktest.Ticket{
+		{ID: 1, Status: "open", Tags: []string{"beta"}, Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 30}}},
+		{ID: 2, Status: "solved", Tags: []string{"beta"}},
+		{ID: 3, Status: "open", Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 90}}},
+		{ID: 4, Status: "pending", Tags: []string{"beta"}, Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 60}}},
+	})
+	fake.PageSize = 1
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c, err := newClient(&conf.Auth{URL: server.URL, Email: "test@example.com", APIKey: "12345"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm, err := c.TicketMetrics(&Query{Params: "type:ticket status<solved tags:beta"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var replyTimes []int
+	for _, ticket := range tm.Tickets {
+		replyTimes = append(replyTimes, *ticket.Metrics.ReplyTime.Calendar)
+	}
+
+	if !reflect.DeepEqual(replyTimes, []int{30, 60}) {
+		t.Errorf("Expected reply times [30 60] but got %v", replyTimes)
+	}
+
+	fake.RateLimit(1, 60)
+
+	_, err = c.SearchTickets(&Query{Params: "type:ticket"})
+	expected := "Zendesk request failed with status 429: Number of allowed API requests per minute exceeded"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q but got %v", expected, err)
+	}
+}
@@ -0,0 +1,284 @@
+package zendesk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+	"github.com/geckoboard/zendesk_dataset/geckoboard/geckoboardtest"
+	"github.com/geckoboard/zendesk_dataset/zendesk/zendesktest"
+)
+
+const endToEndConfig = `
+geckoboard:
+  api_key: Ap1K4y
+  url: %s
+geckoboard_accounts:
+  marketing:
+    api_key: M4rk3t1ng
+    url: %s
+zendesk:
+  auth:
+    email: test@example.com
+    api_key: '12345'
+    url: %s
+  reports:
+  - name: ticket_counts
+    dataset: tickets.by.tag
+    group_by:
+      key: 'tags:'
+      name: Tag
+    filter:
+      date_range:
+      - past: 30
+        unit: day
+      values:
+        'tags:': [beta, vip]
+  - name: ticket_counts_by_day
+    dataset: tickets.by.day
+    filter:
+      date_range:
+      - past: 30
+        unit: day
+  - name: detailed_metrics
+    dataset: reply.time.groups
+    metric_options:
+      attribute: reply_time
+      unit: calendar
+      grouping:
+      - from: 0
+        to: 1
+        unit: hour
+      - from: 1
+        to: 4
+        unit: hour
+      percentage: true
+    filter:
+      date_range:
+      - past: 30
+        unit: day
+  - name: average_metrics
+    dataset: reply.time.average
+    mode: append
+    metric_options:
+      attribute: reply_time
+      unit: calendar
+    filter:
+      date_range:
+      - past: 30
+        unit: day
+  - name: ticket_counts
+    dataset: open.tickets.all.brands
+    zendesk_accounts: [default, brand]
+    geckoboard_account: marketing
+    filter:
+      value:
+        'status:': open
+zendesk_accounts:
+  brand:
+    oauth_token: 0auth70k3n
+    url: %s
+`
+
+// endToEndFakes are the fake Zendesk and Geckoboard accounts used by the
+// end to end config.
+type endToEndFakes struct {
+	geckoboard, marketing *geckoboardtest.Geckoboard
+	servers               []*httptest.Server
+}
+
+func newEndToEndFakes() *endToEndFakes {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 10, 0, 0, 0, time.UTC) }
+	old := time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC)
+	replyTime := func(minutes int) zendesktest.MetricSet {
+		return zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: minutes}}
+	}
+
+	f := &endToEndFakes{geckoboard: geckoboardtest.New(), marketing: geckoboardtest.New()}
+
+	f.servers = []*httptest.Server{
+		httptest.NewServer(f.geckoboard),
+		httptest.NewServer(f.marketing),
+		httptest.NewServer(zendesktest.New([]zendesktest.Ticket{
+			{ID: 1, Status: "open", Tags: []string{"beta"}, CreatedAt: day(25), UpdatedAt: day(25), Metrics: replyTime(30)},
+			{ID: 2, Status: "solved", Tags: []string{"beta", "vip"}, CreatedAt: day(25), UpdatedAt: day(26), Metrics: replyTime(90)},
+			{ID: 3, Status: "pending", Tags: []string{"vip"}, CreatedAt: day(28), UpdatedAt: day(29), Metrics: replyTime(200)},
+			{ID: 4, Status: "open", Tags: []string{"beta"}, CreatedAt: old, UpdatedAt: old},
+		})),
+		httptest.NewServer(zendesktest.New([]zendesktest.Ticket{
+			{ID: 1, Status: "open", CreatedAt: day(30), UpdatedAt: day(30)},
+		})),
+	}
+
+	return f
+}
+
+// urls returns the URLs of the Geckoboard, marketing Geckoboard, Zendesk
+// and brand Zendesk accounts in the order of the end to end config.
+func (f *endToEndFakes) urls() []string {
+	urls := make([]string, len(f.servers))
+	for i, s := range f.servers {
+		urls[i] = s.URL
+	}
+
+	return urls
+}
+
+func (f *endToEndFakes) close() {
+	for _, s := range f.servers {
+		s.Close()
+	}
+}
+
+func loadEndToEndConfig(t *testing.T, urls []string) *conf.Config {
+	dir, err := ioutil.TempDir("", "zendesk_dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	config := fmt.Sprintf(endToEndConfig, urls[0], urls[1], urls[2], urls[3])
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := conf.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestHandleReportsEndToEnd(t *testing.T) {
+	fakes := newEndToEndFakes()
+	defer fakes.close()
+
+	c := loadEndToEndConfig(t, fakes.urls())
+
+	clock := AsOf(time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	// Running twice checks rerunning replaces the data, or for the report
+	// in append mode updates the day's record, rather than adding to it.
+	NewRunner(c, RunnerOptions{Clock: clock}).Run()
+	NewRunner(c, RunnerOptions{Clock: clock}).Run()
+
+	fakes.checkRecords(t)
+}
+
+// checkRecords checks the fake Geckoboard accounts have the datasets and
+// records the end to end config's reports send on 2017-02-01.
+func (f *endToEndFakes) checkRecords(t *testing.T) {
+	geckoboard, marketing := f.geckoboard, f.marketing
+
+	third, twoThirds := 1.0/3, 2.0/3
+	average, median := float64(30+90+200)/3, 90.0
+
+	testCases := []struct {
+		fake    *geckoboardtest.Geckoboard
+		dataset string
+		records []gb.Record
+	}{
+		{
+			fake:    geckoboard,
+			dataset: "tickets.by.tag",
+			records: []gb.Record{
+				{"grouped_by": "beta", "ticket_count": float64(2)},
+				{"grouped_by": "vip", "ticket_count": float64(2)},
+			},
+		},
+		{
+			fake:    geckoboard,
+			dataset: "tickets.by.day",
+			records: []gb.Record{
+				{"date": "2017-01-25", "count": float64(2)},
+				{"date": "2017-01-28", "count": float64(1)},
+			},
+		},
+		{
+			fake:    geckoboard,
+			dataset: "reply.time.groups",
+			records: []gb.Record{
+				{"grouping": "0-1 hour", "count": float64(1), "percentage": third},
+				{"grouping": "1-4 hours", "count": float64(2), "percentage": twoThirds},
+			},
+		},
+		{
+			fake:    geckoboard,
+			dataset: "reply.time.average",
+			records: []gb.Record{
+				{"date": "2017-02-01", "average": average, "median": median, "ticket_count": float64(3)},
+			},
+		},
+		{
+			fake:    marketing,
+			dataset: "open.tickets.all.brands",
+			records: []gb.Record{
+				{"grouped_by": "All", "ticket_count": float64(3)},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		if _, ok := tc.fake.DataSet(tc.dataset); !ok {
+			t.Errorf("[spec %d] Expected dataset %s to have been created", i, tc.dataset)
+			continue
+		}
+
+		if records := tc.fake.Records(tc.dataset); !reflect.DeepEqual(records, tc.records) {
+			t.Errorf("[spec %d] Expected dataset %s to have records %v but got %v", i, tc.dataset, tc.records, records)
+		}
+	}
+
+	if ids := marketing.DataSets(); !reflect.DeepEqual(ids, []string{"open.tickets.all.brands"}) {
+		t.Errorf("Expected only the marketing report's dataset in the marketing account but got %v", ids)
+	}
+
+	ds, _ := geckoboard.DataSet("reply.time.average")
+	if !reflect.DeepEqual(ds.UniqueBy, []string{"date"}) {
+		t.Errorf("Expected the append mode dataset to be unique by date but got %v", ds.UniqueBy)
+	}
+}
+
+func TestHandleReportsFromWarehouse(t *testing.T) {
+	fakes := newEndToEndFakes()
+	defer fakes.close()
+
+	dir, err := ioutil.TempDir("", "zendesk_warehouse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := loadEndToEndConfig(t, fakes.urls())
+	c.Zendesk.Warehouse.Path = filepath.Join(dir, "warehouse.json")
+
+	if err := SyncWarehouse(c, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reports only search the warehouse so Zendesk isn't needed.
+	fakes.servers[2].Close()
+	fakes.servers[3].Close()
+
+	for i := range c.Zendesk.Reports {
+		c.Zendesk.Reports[i].Source = conf.WarehouseSource
+	}
+
+	clock := AsOf(time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC))
+	NewRunner(c, RunnerOptions{Clock: clock}).Run()
+
+	fakes.checkRecords(t)
+}
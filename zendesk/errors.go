@@ -0,0 +1,61 @@
+package zendesk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error is returned when Zendesk responds with an unsuccessful status code.
+type Error struct {
+	StatusCode int
+	Message    string
+
+	// RetryAfter is how long Zendesk asks to wait before trying again
+	// when rate limited.
+	RetryAfter time.Duration
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("Zendesk request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// newError builds an Error from the response. Zendesk describes errors either
+// as a string with an optional description or an object with a title and message.
+func newError(resp *http.Response) Error {
+	e := Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+
+	var body struct {
+		Error       json.RawMessage `json:"error"`
+		Description string          `json:"description"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return e
+	}
+
+	var title string
+	var detail struct {
+		Title   string `json:"title"`
+		Message string `json:"message"`
+	}
+
+	switch {
+	case body.Description != "":
+		e.Message = body.Description
+	case json.Unmarshal(body.Error, &title) == nil && title != "":
+		e.Message = title
+	case json.Unmarshal(body.Error, &detail) == nil && detail.Message != "":
+		e.Message = detail.Message
+	case detail.Title != "":
+		e.Message = detail.Title
+	}
+
+	return e
+}
@@ -2,7 +2,8 @@ package zendesk
 
 import (
 	"fmt"
-	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/geckoboard/zendesk_dataset/conf"
@@ -14,172 +15,425 @@ const (
 	TicketCountsReport      = "ticket_counts"
 	TicketCountsByDayReport = "ticket_counts_by_day"
 	DetailedMetricsReport   = "detailed_metrics"
+	AverageMetricsReport    = "average_metrics"
 
 	dateFormat = "2006-01-02"
-)
 
-var timeNow = time.Now()
+	// dateField is the dataset field holding the day a record relates to,
+	// reports in append mode add it so each run builds up history.
+	dateField = "date"
+
+	// ungroupedName names the only group of ticket counts which aren't grouped.
+	ungroupedName = "All"
+)
 
 // HandleReports takes a conf.Config and iterates over the Zendesk.Reports
-// calling the method based on the Report.Name attribute if any errors
-// occurs while processing a report it extracts the error and presents it
-// to the user or prints that report was successfull and continues
-// with the next report if any.
-func HandleReports(c *conf.Config) {
-	var err error
-
-	for _, r := range c.Zendesk.Reports {
-
-		switch r.Name {
-		case TicketCountsReport:
-			err = ticketCount(&r, c)
-		case DetailedMetricsReport:
-			err = detailedMetrics(&r, c)
-		case TicketCountsByDayReport:
-			err = ticketCountsByDay(&r, c)
-		default:
-			err = fmt.Errorf("Report name %s was not found", r.Name)
-		}
+// running the template registered with the Report.Name attribute if any
+// errors occurs while processing a report it extracts the error and presents
+// it to the user or prints that report was successfull and continues
+// with the next report if any. The reports share a cache of the Zendesk
+// search results and metric sets, so tickets are only fetched once. It
+// returns an error saying how many reports failed when any did.
+func HandleReports(c *conf.Config) error {
+	return NewRunner(c, RunnerOptions{}).Run()
+}
 
-		if err != nil {
-			log.Printf("ERRO: Processing report '%s' failed with: %s", r.DataSet, err.Error())
-			err = nil
-		}
+func init() {
+	RegisterTemplate(TicketCountsReport, ticketCounts{})
+	RegisterTemplate(TicketCountsByDayReport, ticketCountsByDay{})
+	RegisterTemplate(DetailedMetricsReport, detailedMetrics{})
+	RegisterTemplate(AverageMetricsReport, averageMetrics{})
+}
+
+// ticketCounts counts the tickets found by the report's filter, or for each
+// of the values of its group by key.
+type ticketCounts struct{}
+
+type ticketCountRecord struct {
+	Date        string `json:"date,omitempty"`
+	GroupedBy   string `json:"grouped_by"`
+	TicketCount int    `json:"ticket_count"`
+}
 
-		log.Printf("INFO: Processing report '%s' completed successfully", r.DataSet)
+func (ticketCounts) Help() TemplateHelp {
+	return TemplateHelp{
+		Title: "Ticket counts",
+		Summary: `Counts the tickets the filter finds, or the tickets for each of the values of
+the group_by key, such as a count for each tag.`,
+		Example: `name: ticket_counts
+dataset: zendesk.tickets.by.tag
+group_by:
+  key: 'tags:'
+  name: Tag
+filter:
+  date_range:
+    - past: 30
+      unit: day
+  values:
+    'tags:':
+      - beta
+      - enterprise`,
 	}
 }
 
-func ticketCount(r *conf.Report, c *conf.Config) error {
-	type GData struct {
-		GroupedBy   string `json:"grouped_by"`
-		TicketCount int    `json:"ticket_count"`
+func (ticketCounts) Validate(r *conf.Report) error {
+	if r.GroupBy.Key != "" && len(r.Filter.Values[r.GroupBy.Key]) == 0 {
+		return fmt.Errorf("Group by values key '%s' returned no values to group by", r.GroupBy.Key)
 	}
 
-	client := newClient(&c.Zendesk.Auth, false)
+	return nil
+}
 
-	var gbData []GData
+func (ticketCounts) Fetch(r *conf.Report, s TicketSearcher, now time.Time) ([]TicketGroup, error) {
+	queries, err := groupedQueries(r, now)
+	if err != nil {
+		return nil, err
+	}
 
-	if r.GroupBy.Key != "" {
-		values := r.Filter.Values[r.GroupBy.Key]
-		if len(values) == 0 {
-			return fmt.Errorf("Group by values key '%s' returned no values to group by", r.GroupBy.Key)
+	var groups []TicketGroup
+	for _, q := range queries {
+		count, err := s.CountTickets(&Query{Params: q.Query})
+		if err != nil {
+			return nil, err
 		}
 
-		delete(r.Filter.Values, r.GroupBy.Key)
-
-		for _, v := range values {
-			r.Filter.Values[r.GroupBy.Key] = []string{v}
-
-			tp, err := client.SearchTickets(&Query{Params: r.Filter.BuildQuery(&timeNow)})
-			if err != nil {
-				return err
-			}
-
-			gbData = append(gbData, GData{GroupedBy: v, TicketCount: tp.Count})
-		}
-	} else {
-		r.GroupBy.Name = "All"
+		groups = append(groups, TicketGroup{Name: q.Group, Count: count})
+	}
 
-		tp, err := client.SearchTickets(&Query{Params: r.Filter.BuildQuery(&timeNow)})
-		if err != nil {
-			return err
-		}
+	return groups, nil
+}
 
-		gbData = append(gbData, GData{GroupedBy: r.GroupBy.Name, TicketCount: tp.Count})
+func (ticketCounts) Schema(r *conf.Report, mode conf.ReportMode) *gb.DataSet {
+	name := r.GroupBy.DisplayName()
+	if r.GroupBy.Key == "" {
+		name = ungroupedName
 	}
 
 	schema := gb.DataSet{
 		ID: r.DataSet,
 		Fields: gb.Fields{
-			"grouped_by":   gb.Field{Type: gb.StringFieldType, Name: r.GroupBy.DisplayName()},
+			"grouped_by":   gb.Field{Type: gb.StringFieldType, Name: name},
 			"ticket_count": gb.Field{Type: gb.NumberFieldType, Name: "Ticket Count"},
 		},
 	}
 
-	return pushToGeckoboard(&c.Geckoboard, &schema, gbData)
+	if mode == conf.AppendMode {
+		addDateField(&schema, "grouped_by")
+	}
+
+	return &schema
 }
 
-func detailedMetrics(r *conf.Report, c *conf.Config) error {
-	if err := r.MetricOptions.Valid(); err != nil {
-		return err
+func (ticketCounts) Records(r *conf.Report, groups []TicketGroup, date string) interface{} {
+	var records []ticketCountRecord
+	for _, g := range groups {
+		records = append(records, ticketCountRecord{Date: date, GroupedBy: g.Name, TicketCount: g.Count})
 	}
 
-	if err := r.MetricOptions.GroupingValid(); err != nil {
-		return err
+	return records
+}
+
+// Explain returns the query for each of the groups prefixed with the group.
+func (ticketCounts) Explain(r *conf.Report, now time.Time) ([]string, error) {
+	queries, err := groupedQueries(r, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.GroupBy.Key == "" {
+		return []string{queries[0].Query}, nil
 	}
 
-	type MetricData struct {
-		Grouping string `json:"grouping"`
-		Count    int    `json:"count"`
+	explained := make([]string, len(queries))
+	for i, q := range queries {
+		explained[i] = fmt.Sprintf("%s: %s", q.Group, q.Query)
 	}
 
-	client := newClient(&c.Zendesk.Auth, true)
-	gbData := make([]MetricData, len(r.MetricOptions.Grouping))
+	return explained, nil
+}
 
-	tm, err := client.TicketMetrics(&Query{Params: r.Filter.BuildQuery(&timeNow)})
-	if err != nil {
+// detailedMetrics counts the tickets in each of the groupings of the
+// report's metric, such as the reply time, along with their percentage when
+// the metric options ask for it.
+type detailedMetrics struct{}
+
+type metricRecord struct {
+	Date       string   `json:"date,omitempty"`
+	Grouping   string   `json:"grouping"`
+	Count      int      `json:"count"`
+	Percentage *float64 `json:"percentage,omitempty"`
+}
+
+func (detailedMetrics) Help() TemplateHelp {
+	return TemplateHelp{
+		Title: "Detailed ticket metrics",
+		Summary: `Counts the tickets the filter finds in each of the groupings of a ticket
+metric, such as first reply times of under an hour. Set percentage in the
+metric options to add the share of the tickets in each.`,
+		Example: `name: detailed_metrics
+dataset: zendesk.reply.times
+metric_options:
+  attribute: reply_time
+  unit: calendar
+  grouping:
+    - to: 1
+      unit: hour
+    - from: 1
+      to: 8
+      unit: hour
+    - from: 8
+      to: 24
+      unit: hour
+filter:
+  date_range:
+    - past: 7
+      unit: day`,
+	}
+}
+
+func (detailedMetrics) Validate(r *conf.Report) error {
+	if err := r.MetricOptions.Valid(); err != nil {
 		return err
 	}
 
+	return r.MetricOptions.GroupingValid()
+}
+
+func (detailedMetrics) Fetch(r *conf.Report, s TicketSearcher, now time.Time) ([]TicketGroup, error) {
+	return fetchMetrics(r, s, now)
+}
+
+func (detailedMetrics) Schema(r *conf.Report, mode conf.ReportMode) *gb.DataSet {
+	schema := gb.DataSet{
+		ID: r.DataSet,
+		Fields: gb.Fields{
+			"grouping": gb.Field{Type: gb.StringFieldType, Name: "Grouping"},
+			"count":    gb.Field{Type: gb.NumberFieldType, Name: "Count"},
+		},
+	}
+
+	if r.MetricOptions.Percentage {
+		schema.Fields["percentage"] = gb.Field{Type: gb.PercentageFieldType, Name: "Percentage of tickets"}
+	}
+
+	if mode == conf.AppendMode {
+		addDateField(&schema, "grouping")
+	}
+
+	return &schema
+}
+
+func (detailedMetrics) Records(r *conf.Report, groups []TicketGroup, date string) interface{} {
+	values := metricValues(r, groups[0].Tickets)
+	records := make([]metricRecord, len(r.MetricOptions.Grouping))
+
 	// Group the data as per the user requirements.
 	for idx, grp := range r.MetricOptions.Grouping {
 		var count int
-		d := MetricData{Grouping: grp.DisplayName()}
-
-		for _, t := range tm.Tickets {
-			var tMetric int
-
-			switch r.MetricOptions.Unit {
-			case conf.BusinessMetric:
-				tMetric = t.subTimeMetric(r.MetricOptions.Attribute).Business
-			case conf.CalendarMetric:
-				tMetric = t.subTimeMetric(r.MetricOptions.Attribute).Calendar
-			}
+		d := metricRecord{Date: date, Grouping: grp.DisplayName()}
 
+		for _, tMetric := range values {
 			if tMetric >= grp.FromInMinutes() && tMetric < grp.ToInMinutes() {
 				count++
 			}
 		}
 
 		d.Count = count
-		gbData[idx] = d
+		if r.MetricOptions.Percentage {
+			var percentage float64
+			if len(values) > 0 {
+				percentage = float64(count) / float64(len(values))
+			}
+
+			d.Percentage = &percentage
+		}
+
+		records[idx] = d
+	}
+
+	return records
+}
+
+// averageMetrics takes the average and median of the report's metric.
+type averageMetrics struct{}
+
+// averageRecord has pointers for the average and median so they are sent
+// as null when no tickets have the metric to take them from. The ticket
+// count is of the tickets with the metric.
+type averageRecord struct {
+	Date        string   `json:"date,omitempty"`
+	Average     *float64 `json:"average"`
+	Median      *float64 `json:"median"`
+	TicketCount int      `json:"ticket_count"`
+}
+
+func (averageMetrics) Help() TemplateHelp {
+	return TemplateHelp{
+		Title: "Average ticket metrics",
+		Summary: `Takes the average and median of a ticket metric, such as the first reply
+time, of the tickets the filter finds which have a value for it.`,
+		Example: `name: average_metrics
+dataset: zendesk.average.reply.time
+metric_options:
+  attribute: reply_time
+  unit: business
+filter:
+  date_range:
+    - past: 7
+      unit: day`,
 	}
+}
+
+func (averageMetrics) Validate(r *conf.Report) error {
+	if err := r.MetricOptions.Valid(); err != nil {
+		return err
+	}
+
+	if r.MetricOptions.Percentage {
+		return conf.ErrPercentageNotGrouped
+	}
+
+	return nil
+}
+
+func (averageMetrics) Fetch(r *conf.Report, s TicketSearcher, now time.Time) ([]TicketGroup, error) {
+	return fetchMetrics(r, s, now)
+}
+
+func (averageMetrics) Schema(r *conf.Report, mode conf.ReportMode) *gb.DataSet {
+	// Name the fields after the metric so reply_time reads "Average reply time".
+	name := strings.Replace(string(r.MetricOptions.Attribute), "_", " ", -1)
 
 	schema := gb.DataSet{
 		ID: r.DataSet,
 		Fields: gb.Fields{
-			"grouping": gb.Field{Type: gb.StringFieldType, Name: "Grouping"},
-			"count":    gb.Field{Type: gb.NumberFieldType, Name: "Count"},
+			"average":      gb.Field{Type: gb.DurationFieldType, Name: "Average " + name, TimeUnit: gb.Minutes, Optional: true},
+			"median":       gb.Field{Type: gb.DurationFieldType, Name: "Median " + name, TimeUnit: gb.Minutes, Optional: true},
+			"ticket_count": gb.Field{Type: gb.NumberFieldType, Name: "Ticket Count"},
 		},
 	}
 
-	return pushToGeckoboard(&c.Geckoboard, &schema, gbData)
+	if mode == conf.AppendMode {
+		addDateField(&schema)
+	}
+
+	return &schema
 }
 
-func ticketCountsByDay(r *conf.Report, c *conf.Config) error {
+func (averageMetrics) Records(r *conf.Report, groups []TicketGroup, date string) interface{} {
+	values := metricValues(r, groups[0].Tickets)
+
+	d := averageRecord{Date: date, TicketCount: len(values)}
+
+	if len(values) > 0 {
+		var total int
+		for _, v := range values {
+			total += v
+		}
+
+		sort.Ints(values)
+		mid := len(values) / 2
+		median := float64(values[mid])
+		if len(values)%2 == 0 {
+			median = float64(values[mid-1]+values[mid]) / 2
+		}
 
-	type DateData struct {
-		Date  string `json:"date"`
-		Count int    `json:"count"`
+		average := float64(total) / float64(len(values))
+		d.Average = &average
+		d.Median = &median
 	}
 
-	client := newClient(&c.Zendesk.Auth, true)
+	return []averageRecord{d}
+}
+
+// metricValues returns the report's metric of each of the tickets which has
+// a value, leaving out those without one such as unsolved tickets when
+// reporting resolution times.
+func metricValues(r *conf.Report, tickets []Ticket) []int {
+	var values []int
+	for _, t := range tickets {
+		if v, ok := t.metricValue(&r.MetricOptions); ok {
+			values = append(values, v)
+		}
+	}
 
-	var gbData []DateData
+	return values
+}
 
-	tp, err := client.SearchTickets(&Query{Params: r.Filter.BuildQuery(&timeNow)})
+// fetchMetrics returns the tickets the report's filter finds with their
+// metric sets as a single group.
+func fetchMetrics(r *conf.Report, s TicketSearcher, now time.Time) ([]TicketGroup, error) {
+	tm, err := s.TicketMetrics(&Query{Params: r.Filter.BuildQuery(&now)})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return []TicketGroup{{Count: tm.Count, Tickets: tm.Tickets}}, nil
+}
+
+// ticketCountsByDay counts the tickets the report's filter finds by the
+// day they were created.
+type ticketCountsByDay struct{}
+
+type dayCountRecord struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+func (ticketCountsByDay) Help() TemplateHelp {
+	return TemplateHelp{
+		Title: "Ticket counts by day",
+		Summary: `Counts the tickets the filter finds by the day they were created, oldest
+first. The records are unique by the date in append mode.`,
+		Example: `name: ticket_counts_by_day
+dataset: zendesk.tickets.by.day
+filter:
+  date_range:
+    - past: 14
+      unit: day`,
 	}
+}
 
-	for _, t := range tp.Tickets {
+func (ticketCountsByDay) Validate(r *conf.Report) error {
+	return nil
+}
+
+func (ticketCountsByDay) Fetch(r *conf.Report, s TicketSearcher, now time.Time) ([]TicketGroup, error) {
+	tp, err := s.SearchTickets(&Query{Params: r.Filter.BuildQuery(&now)})
+	if err != nil {
+		return nil, err
+	}
+
+	return []TicketGroup{{Count: tp.Count, Tickets: tp.Tickets}}, nil
+}
+
+func (ticketCountsByDay) Schema(r *conf.Report, mode conf.ReportMode) *gb.DataSet {
+	schema := gb.DataSet{
+		ID: r.DataSet,
+		Fields: gb.Fields{
+			"date":  gb.Field{Type: gb.DateFieldType, Name: "Date"},
+			"count": gb.Field{Type: gb.NumberFieldType, Name: "Ticket Count"},
+		},
+	}
+
+	if mode == conf.AppendMode {
+		schema.UniqueBy = []string{dateField}
+	}
+
+	return &schema
+}
+
+// Records ignores the date of the run as the records are already by day.
+func (ticketCountsByDay) Records(r *conf.Report, groups []TicketGroup, date string) interface{} {
+	var records []dayCountRecord
+
+	for _, t := range groups[0].Tickets {
 		found := false
 		td := t.CreatedAt.Format(dateFormat)
 
-		for i, dc := range gbData {
+		for i, dc := range records {
 			if td == dc.Date {
-				gbData[i].Count++
+				records[i].Count++
 				found = true
 				break
 			}
@@ -189,36 +443,64 @@ func ticketCountsByDay(r *conf.Report, c *conf.Config) error {
 			continue
 		}
 
-		gbData = append(gbData, DateData{Date: td, Count: 1})
+		records = append(records, dayCountRecord{Date: td, Count: 1})
 	}
 
-	schema := gb.DataSet{
-		ID: r.DataSet,
-		Fields: gb.Fields{
-			"date":  gb.Field{Type: gb.DateFieldType, Name: "Date"},
-			"count": gb.Field{Type: gb.NumberFieldType, Name: "Ticket Count"},
-		},
-	}
+	// Order oldest first so if there are more days than the dataset can
+	// hold it is the oldest that are dropped.
+	sort.Slice(records, func(i, j int) bool { return records[i].Date < records[j].Date })
 
-	return pushToGeckoboard(&c.Geckoboard, &schema, gbData)
+	return records
 }
 
-func pushToGeckoboard(c *conf.Geckoboard, schema *gb.DataSet, data interface{}) error {
-	//Create the dataset schema
-	gConf := gb.New(gb.Config{
-		Key: c.APIKey,
-		URL: c.URL,
-	})
+// groupQuery is the search query for one of the groups of a report.
+type groupQuery struct {
+	Group string
+	Query string
+}
 
-	err := schema.FindOrCreate(gConf)
-	if err != nil {
-		return err
+// groupedQueries returns a search query for each of the values of the
+// report's group by key, or a single query named All when the report
+// isn't grouped. The report's filter isn't changed.
+func groupedQueries(r *conf.Report, now time.Time) ([]groupQuery, error) {
+	if r.GroupBy.Key == "" {
+		return []groupQuery{{Group: ungroupedName, Query: r.Filter.BuildQuery(&now)}}, nil
 	}
 
-	err = schema.SendAll(gConf, data)
-	if err != nil {
-		return err
+	values := r.Filter.Values[r.GroupBy.Key]
+	if len(values) == 0 {
+		return nil, fmt.Errorf("Group by values key '%s' returned no values to group by", r.GroupBy.Key)
 	}
 
-	return nil
+	filter := r.Filter
+	filter.Values = map[string][]string{}
+	for k, v := range r.Filter.Values {
+		filter.Values[k] = v
+	}
+
+	var queries []groupQuery
+	for _, v := range values {
+		filter.Values[r.GroupBy.Key] = []string{v}
+		queries = append(queries, groupQuery{Group: v, Query: filter.BuildQuery(&now)})
+	}
+
+	return queries, nil
+}
+
+// runDate returns the date of this run for reports in append mode, otherwise
+// an empty string so the date is omitted from the records.
+func runDate(mode conf.ReportMode, now time.Time) string {
+	if mode != conf.AppendMode {
+		return ""
+	}
+
+	return now.Format(dateFormat)
+}
+
+// addDateField adds the date field to the schema and makes the records unique
+// by the date and the other given fields, so rerunning a report on the same
+// day updates that day's records rather than duplicating them.
+func addDateField(schema *gb.DataSet, uniqueBy ...string) {
+	schema.Fields[dateField] = gb.Field{Type: gb.DateFieldType, Name: "Date"}
+	schema.UniqueBy = append([]string{dateField}, uniqueBy...)
 }
@@ -3,13 +3,16 @@ package zendesk
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
 )
 
 type ReportTestCase struct {
@@ -143,7 +146,8 @@ func TestHandleReports(t *testing.T) {
 				{
 					FullPath: "/datasets/ticket_metrics_in_last_3days",
 					RequestBody: `{"id":"ticket_metrics_in_last_3days","fields":{"count":{"name":"Count","type":"number"},` +
-						`"grouping":{"name":"Grouping","type":"string"}},"created_at":"0001-01-01T00:00:00Z",` +
+						`"grouping":{"name":"Grouping","type":"string"}},` +
+						`"created_at":"0001-01-01T00:00:00Z",` +
 						`"updated_at":"0001-01-01T00:00:00Z"}`,
 					ResponseBody: "{}\n",
 				},
@@ -204,13 +208,15 @@ func TestHandleReports(t *testing.T) {
 				{
 					FullPath: "/datasets/ticket_metrics_in_last_3days",
 					RequestBody: `{"id":"ticket_metrics_in_last_3days","fields":{"count":{"name":"Count","type":"number"},` +
-						`"grouping":{"name":"Grouping","type":"string"}},"created_at":"0001-01-01T00:00:00Z",` +
+						`"grouping":{"name":"Grouping","type":"string"},"percentage":{"name":"Percentage of tickets","type":"percentage"}},` +
+						`"created_at":"0001-01-01T00:00:00Z",` +
 						`"updated_at":"0001-01-01T00:00:00Z"}`,
 					ResponseBody: "{}\n",
 				},
 				{
 					FullPath:     "/datasets/ticket_metrics_in_last_3days/data",
-					RequestBody:  `{"data":[{"grouping":"0-1 hour","count":0},{"grouping":"60-480 minutes","count":3},{"grouping":"480-800 minutes","count":1}]}`,
+					RequestBody:  `{"data":[{"grouping":"0-1 hour","count":0,"percentage":0},{"grouping":"60-480 minutes","count":3,"percentage":0.75},` +
+						`{"grouping":"480-800 minutes","count":1,"percentage":0.25}]}`,
 					ResponseBody: "{}\n",
 				},
 			},
@@ -239,6 +245,7 @@ func TestHandleReports(t *testing.T) {
 									{Unit: "minute", From: 60, To: 480},
 									{Unit: "minute", From: 480, To: 800},
 								},
+								Percentage: true,
 							},
 						},
 					},
@@ -264,7 +271,7 @@ func TestHandleReports(t *testing.T) {
 				},
 				{
 					FullPath:     "/datasets/tickets.last.month.by.day/data",
-					RequestBody:  `{"data":[{"date":"2016-06-29","count":2},{"date":"2016-06-30","count":1},{"date":"2016-07-01","count":4},{"date":"2016-07-05","count":1},{"date":"2016-07-04","count":1}]}`,
+					RequestBody:  `{"data":[{"date":"2016-06-29","count":2},{"date":"2016-06-30","count":1},{"date":"2016-07-01","count":4},{"date":"2016-07-04","count":1},{"date":"2016-07-05","count":1}]}`,
 					ResponseBody: "{}\n",
 				},
 			},
@@ -290,6 +297,240 @@ func TestHandleReports(t *testing.T) {
 				},
 			},
 		},
+		{
+			ExpectedTotalRequestCount: 3,
+			ZendeskRequests: []ERequest{
+				{
+					FullPath:     "/api/v2/search.json?query=type%3Aticket+status%3Aopen",
+					ResponseBody: `{"results": [{"id": 1},{"id":2}],"count": 2}`,
+				},
+			},
+			GeckoboardRequests: []ERequest{
+				{
+					FullPath: "/datasets/open.tickets.history",
+					RequestBody: `{"id":"open.tickets.history","fields":{"date":{"name":"Date","type":"date"},` +
+						`"grouped_by":{"name":"All","type":"string"},"ticket_count":{"name":"Ticket Count","type":"number"}},` +
+						`"unique_by":["date","grouped_by"],"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`,
+					ResponseBody: "{}\n",
+				},
+				{
+					FullPath:     "/datasets/open.tickets.history/data",
+					RequestBody:  `{"data":[{"date":"2016-06-01","grouped_by":"All","ticket_count":2}],"delete_by":"date"}`,
+					ResponseBody: "{}\n",
+				},
+			},
+			Config: conf.Config{
+				Geckoboard: conf.Geckoboard{
+					URL: "",
+				},
+				Zendesk: conf.Zendesk{
+					Reports: []conf.Report{
+						{
+							Name:    "ticket_counts",
+							DataSet: "open.tickets.history",
+							Mode:    conf.AppendMode,
+							Filter: conf.SearchFilter{
+								Value: map[string]string{
+									"status:": "open",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			ExpectedTotalRequestCount: 3,
+			ZendeskRequests: []ERequest{
+				{
+					FullPath:     "/api/v2/search.json?query=type%3Aticket+created%3E%3D2016-05-31",
+					ResponseBody: `{"results": [{"created_at": "2016-05-31T19:59:14Z"},{"created_at": "2016-06-01T09:59:14Z"}]}`,
+				},
+			},
+			GeckoboardRequests: []ERequest{
+				{
+					FullPath: "/datasets/tickets.by.day.history",
+					RequestBody: `{"id":"tickets.by.day.history","fields":{"count":{"name":"Ticket Count","type":"number"},"date":{"name":"Date","type":"date"}},` +
+						`"unique_by":["date"],"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`,
+					ResponseBody: "{}\n",
+				},
+				{
+					FullPath:     "/datasets/tickets.by.day.history/data",
+					RequestBody:  `{"data":[{"date":"2016-05-31","count":1},{"date":"2016-06-01","count":1}],"delete_by":"date"}`,
+					ResponseBody: "{}\n",
+				},
+			},
+			Config: conf.Config{
+				Geckoboard: conf.Geckoboard{
+					URL: "",
+				},
+				Zendesk: conf.Zendesk{
+					Reports: []conf.Report{
+						{
+							Name:    "ticket_counts_by_day",
+							DataSet: "tickets.by.day.history",
+							Mode:    conf.AppendMode,
+							Filter: conf.SearchFilter{
+								DateRange: conf.DateFilters{
+									{
+										Unit: "day",
+										Past: 1,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			ExpectedTotalRequestCount: 4,
+			ZendeskRequests: []ERequest{
+				{
+					FullPath:     "/api/v2/search.json?query=type%3Aticket+created%3E%3D2016-05-29",
+					ResponseBody: `{"results":[ {"id": 1},{"id": 2},{"id": 3},{"id": 4}]}`,
+				},
+				{
+					FullPath: "/api/v2/tickets/show_many.json?ids=1%2C2%2C3%2C4&include=metric_sets",
+					ResponseBody: `{"tickets":[
+					{"metric_set": {"reply_time_in_minutes": {"calendar": 70, "business": 59}}},
+					{"metric_set": {"reply_time_in_minutes": {"calendar": 120, "business": 60}}},
+					{"metric_set": {"reply_time_in_minutes": {"calendar": 181, "business": 121}}},
+					{"metric_set": {"reply_time_in_minutes": {"calendar": 185, "business": null}}}
+					] }`,
+				},
+			},
+			GeckoboardRequests: []ERequest{
+				{
+					FullPath: "/datasets/average_reply_time",
+					RequestBody: `{"id":"average_reply_time","fields":{` +
+						`"average":{"name":"Average reply time","type":"duration","optional":true,"time_unit":"minutes"},` +
+						`"median":{"name":"Median reply time","type":"duration","optional":true,"time_unit":"minutes"},` +
+						`"ticket_count":{"name":"Ticket Count","type":"number"}},` +
+						`"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`,
+					ResponseBody: "{}\n",
+				},
+				{
+					FullPath:     "/datasets/average_reply_time/data",
+					RequestBody:  `{"data":[{"average":80,"median":60,"ticket_count":3}]}`,
+					ResponseBody: "{}\n",
+				},
+			},
+			Config: conf.Config{
+				Geckoboard: conf.Geckoboard{
+					URL: "",
+				},
+				Zendesk: conf.Zendesk{
+					Reports: []conf.Report{
+						{
+							Name:    "average_metrics",
+							DataSet: "average_reply_time",
+							Filter: conf.SearchFilter{
+								DateRange: conf.DateFilters{
+									{
+										Unit: "day",
+										Past: 3,
+									},
+								},
+							},
+							MetricOptions: conf.MetricOption{
+								Attribute: conf.ReplyTime,
+								Unit:      conf.BusinessMetric,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			ExpectedTotalRequestCount: 3,
+			ZendeskRequests: []ERequest{
+				{
+					FullPath:     "/api/v2/search.json?query=type%3Aticket+status%3Anew",
+					ResponseBody: `{"results":[]}`,
+				},
+			},
+			GeckoboardRequests: []ERequest{
+				{
+					FullPath: "/datasets/average_new_reply_time",
+					RequestBody: `{"id":"average_new_reply_time","fields":{` +
+						`"average":{"name":"Average reply time","type":"duration","optional":true,"time_unit":"minutes"},` +
+						`"median":{"name":"Median reply time","type":"duration","optional":true,"time_unit":"minutes"},` +
+						`"ticket_count":{"name":"Ticket Count","type":"number"}},` +
+						`"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`,
+					ResponseBody: "{}\n",
+				},
+				{
+					FullPath:     "/datasets/average_new_reply_time/data",
+					RequestBody:  `{"data":[{"average":null,"median":null,"ticket_count":0}]}`,
+					ResponseBody: "{}\n",
+				},
+			},
+			Config: conf.Config{
+				Geckoboard: conf.Geckoboard{
+					URL: "",
+				},
+				Zendesk: conf.Zendesk{
+					Reports: []conf.Report{
+						{
+							Name:    "average_metrics",
+							DataSet: "average_new_reply_time",
+							Filter: conf.SearchFilter{
+								Value: map[string]string{
+									"status:": "new",
+								},
+							},
+							MetricOptions: conf.MetricOption{
+								Attribute: conf.ReplyTime,
+								Unit:      conf.CalendarMetric,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			ExpectedTotalRequestCount: 4,
+			ZendeskRequests: []ERequest{
+				{
+					FullPath:     "/api/v2/search.json?query=type%3Aticket+status%3Aopen",
+					ResponseBody: `{"results": [{"id": 1}, {"id": 2}], "count": 2}`,
+				},
+			},
+			GeckoboardRequests: []ERequest{
+				{
+					FullPath: "/datasets/open.tickets.all.brands",
+					RequestBody: `{"id":"open.tickets.all.brands","fields":{"grouped_by":{"name":"All","type":"string"},` +
+						`"ticket_count":{"name":"Ticket Count","type":"number"}},` +
+						`"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`,
+					ResponseBody: "{}\n",
+				},
+				{
+					FullPath:     "/datasets/open.tickets.all.brands/data",
+					RequestBody:  `{"data":[{"grouped_by":"All","ticket_count":4}]}`,
+					ResponseBody: "{}\n",
+				},
+			},
+			Config: conf.Config{
+				ZendeskAccounts: map[string]conf.Auth{
+					"brand": {Email: "brand@example.com", APIKey: "12345"},
+				},
+				Zendesk: conf.Zendesk{
+					Reports: []conf.Report{
+						{
+							Name:            "ticket_counts",
+							DataSet:         "open.tickets.all.brands",
+							ZendeskAccounts: []string{"default", "brand"},
+							Filter: conf.SearchFilter{
+								Value: map[string]string{
+									"status:": "open",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -298,13 +539,16 @@ func TestHandleReports(t *testing.T) {
 		defer zserver.Close()
 		defer gserver.Close()
 
-		//Required by the client buildRequest method that %s
-		scheme = "http"
-		host = "%s" + strings.Replace(zserver.URL, "http://", "", 1)
-		timeNow = time.Date(2016, 06, 01, 0, 0, 0, 0, time.UTC)
+		tc.Config.Zendesk.Auth.URL = zserver.URL
+		for n, a := range tc.Config.ZendeskAccounts {
+			a.URL = zserver.URL
+			tc.Config.ZendeskAccounts[n] = a
+		}
+
 		tc.Config.Geckoboard.URL = gserver.URL
 
-		HandleReports(&tc.Config)
+		clock := AsOf(time.Date(2016, 06, 01, 0, 0, 0, 0, time.UTC))
+		NewRunner(&tc.Config, RunnerOptions{Clock: clock}).Run()
 
 		if tc.RequestCount != tc.ExpectedTotalRequestCount {
 			t.Errorf("Expected %d requests but got %d", tc.ExpectedTotalRequestCount, tc.RequestCount)
@@ -354,8 +598,76 @@ func buildGeckoboardServerWithExpectations(s *ReportTestCase, t *testing.T) *htt
 			}
 		}
 
-		t.Fatal("No matching requests found for: %v", r)
+		t.Fatalf("No matching requests found for: %v", r)
 	}))
 
 	return server
 }
+
+func TestGeckoboardSinkSchemaChange(t *testing.T) {
+	testCases := []struct {
+		recreate         bool
+		expectedRequests []string
+		err              string
+	}{
+		{
+			expectedRequests: []string{"PUT /datasets/tickets", "GET /datasets/tickets"},
+			err: "Dataset 'tickets' already exists with a different schema: field 'count' has type string " +
+				"but number is expected, set recreate_on_schema_change to delete and recreate it",
+		},
+		{
+			recreate: true,
+			expectedRequests: []string{
+				"PUT /datasets/tickets",
+				"GET /datasets/tickets",
+				"DELETE /datasets/tickets",
+				"PUT /datasets/tickets",
+				"PUT /datasets/tickets/data",
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		var requests []string
+		deleted := false
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+
+			switch {
+			case r.Method == "GET":
+				fmt.Fprint(w, `{"id":"tickets","fields":{"count":{"name":"Count","type":"string"}}}`)
+			case r.Method == "DELETE":
+				deleted = true
+				fmt.Fprint(w, "{}")
+			case r.URL.Path == "/datasets/tickets" && !deleted:
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"error":{"message":"Conflict"}}`)
+			default:
+				fmt.Fprint(w, "{}")
+			}
+		}))
+		defer server.Close()
+
+		schema := gb.DataSet{
+			ID:     "tickets",
+			Fields: gb.Fields{"count": gb.Field{Type: gb.NumberFieldType, Name: "Count"}},
+		}
+
+		r := conf.Report{DataSet: "tickets", RecreateOnSchemaChange: tc.recreate}
+		sink := NewGeckoboardSink(&conf.Config{Geckoboard: conf.Geckoboard{URL: server.URL}}, nil, log.Default())
+		err := sink.Send(&r, &ReportData{Mode: conf.ReplaceMode, Schema: &schema, Records: []gb.Record{}})
+
+		if tc.err == "" && err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+		}
+
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+		}
+
+		if !reflect.DeepEqual(requests, tc.expectedRequests) {
+			t.Errorf("[spec %d] Expected requests %v but got %v", i, tc.expectedRequests, requests)
+		}
+	}
+}
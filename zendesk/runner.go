@@ -0,0 +1,221 @@
+package zendesk
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+)
+
+// Clock returns the time the reports search relative to, such as the
+// tickets created in the past 30 days, and the date of append mode records.
+// Each report asks the clock for the time as it runs.
+type Clock func() time.Time
+
+// AsOf returns a clock which is always at the time, to run the reports as
+// they would have run then.
+func AsOf(t time.Time) Clock {
+	return func() time.Time { return t }
+}
+
+// Logger logs what happens while running the reports, *log.Logger is one.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Sink receives the data built by each of the reports, such as to send it
+// to the report's Geckoboard dataset.
+type Sink interface {
+	Send(r *conf.Report, data *ReportData) error
+}
+
+// ReportData is the dataset a report sends to and the records it built.
+type ReportData struct {
+	Mode    conf.ReportMode
+	Schema  *gb.DataSet
+	Records interface{}
+}
+
+// RunnerOptions replace the defaults a Runner uses, those left unset
+// keep their default.
+type RunnerOptions struct {
+	// HTTPClient makes the Zendesk requests in place of a client built
+	// from each account's timeout, proxy and CA certificate options. The
+	// Geckoboard requests are made by the sink.
+	HTTPClient *http.Client
+	// Clock defaults to the current time.
+	Clock Clock
+	// Logger defaults to the standard logger.
+	Logger Logger
+	// Sink defaults to sending to Geckoboard with NewGeckoboardSink.
+	Sink Sink
+	// RateLimitRetries is how many times a Zendesk request rate limited
+	// by Zendesk is retried, after waiting as long as it asks. It defaults
+	// to not retrying, and backfills retry at least 5 times.
+	RateLimitRetries int
+}
+
+// Runner runs the reports of a config, sharing a cache of the Zendesk search
+// results and metric sets between them so tickets are only fetched once.
+type Runner struct {
+	config     *conf.Config
+	httpClient *http.Client
+	clock      Clock
+	logger     Logger
+	sink       Sink
+
+	cache *cache
+
+	// rateLimitRetries is how many times the Zendesk clients retry a
+	// rate limited request.
+	rateLimitRetries int
+
+	// warehouse is opened by the first report using it.
+	warehouse    *warehouse
+	warehouseErr error
+}
+
+// NewRunner returns a runner for the config's reports using the options.
+// A cache file which can't be read is logged and an empty cache used.
+func NewRunner(c *conf.Config, opts RunnerOptions) *Runner {
+	rn := &Runner{
+		config:     c,
+		httpClient: opts.HTTPClient,
+		clock:      opts.Clock,
+		logger:     opts.Logger,
+		sink:       opts.Sink,
+
+		rateLimitRetries: opts.RateLimitRetries,
+	}
+
+	if rn.clock == nil {
+		rn.clock = time.Now
+	}
+
+	if rn.logger == nil {
+		rn.logger = log.Default()
+	}
+
+	if rn.sink == nil {
+		rn.sink = NewGeckoboardSink(c, nil, rn.logger)
+	}
+
+	var err error
+	if rn.cache, err = newCache(c.Zendesk.Cache); err != nil {
+		rn.logger.Printf("WARN: %s, starting with an empty cache", err.Error())
+	}
+
+	return rn
+}
+
+// Run builds the data of each of the config's reports and sends it to the
+// sink, logging the reports which fail and carrying on with the next, then
+// saves the cache. It returns an error saying how many reports failed when
+// any did.
+func (rn *Runner) Run() error {
+	failed := 0
+
+	for _, r := range rn.config.Zendesk.Reports {
+		if err := rn.runReport(&r); err != nil {
+			rn.logger.Printf("ERRO: Processing report '%s' failed with: %s", r.DataSet, err.Error())
+			failed++
+			continue
+		}
+
+		rn.logger.Printf("INFO: Processing report '%s' completed successfully", r.DataSet)
+	}
+
+	if rn.cache != nil {
+		rn.logger.Printf("INFO: Zendesk cache hits: %s", rn.cache.statistics())
+	}
+
+	if err := rn.SaveCache(); err != nil {
+		rn.logger.Printf("ERRO: %s", err.Error())
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Processing %d of the %d reports failed", failed, len(rn.config.Zendesk.Reports))
+	}
+
+	return nil
+}
+
+func (rn *Runner) runReport(r *conf.Report) error {
+	data, err := rn.Report(r)
+	if err != nil {
+		return err
+	}
+
+	return rn.sink.Send(r, data)
+}
+
+// Report searches for the report's tickets with its template and returns
+// the data built from them without sending it anywhere.
+func (rn *Runner) Report(r *conf.Report) (*ReportData, error) {
+	return rn.reportAsOf(r, rn.clock())
+}
+
+// reportAsOf returns the data of the report as of now.
+func (rn *Runner) reportAsOf(r *conf.Report, now time.Time) (*ReportData, error) {
+	t, err := LookupTemplate(r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Validate(r); err != nil {
+		return nil, err
+	}
+
+	mode, err := r.SendMode()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newReportClient(rn, r)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := t.Fetch(r, client, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReportData{
+		Mode:    mode,
+		Schema:  t.Schema(r, mode),
+		Records: t.Records(r, groups, runDate(mode, now)),
+	}, nil
+}
+
+// SaveCache writes the cache to its file so later runs can use it, which
+// Run does once the reports have run.
+func (rn *Runner) SaveCache() error {
+	return rn.cache.save()
+}
+
+// openWarehouse returns the runner's warehouse, opening it from the path
+// the first time.
+func (rn *Runner) openWarehouse(path string) (*warehouse, error) {
+	if rn.warehouse == nil && rn.warehouseErr == nil {
+		rn.warehouse, rn.warehouseErr = openWarehouse(path)
+	}
+
+	return rn.warehouse, rn.warehouseErr
+}
+
+// newClient returns a paginating client for the account using the runner's
+// HTTP client when it has one.
+func (rn *Runner) newClient(auth *conf.Auth) (*Client, error) {
+	client, err := NewClient(*auth, rn.httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	client.rateLimitRetries = rn.rateLimitRetries
+	client.logger = rn.logger
+	return client, nil
+}
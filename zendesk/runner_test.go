@@ -0,0 +1,155 @@
+package zendesk
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk/zendesktest"
+)
+
+type memorySink struct {
+	sent map[string]*ReportData
+}
+
+func (s *memorySink) Send(r *conf.Report, data *ReportData) error {
+	s.sent[r.DataSet] = data
+	return nil
+}
+
+type memoryLogger []string
+
+func (l *memoryLogger) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+type countingTransport int
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	*c++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRunnerWithOptions(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 10, 0, 0, 0, time.UTC) }
+
+	server := httptest.NewServer(zendesktest.New([]zendesktest.Ticket{
+		{ID: 1, Status: "open", CreatedAt: day(25), Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 30}}},
+		{ID: 2, Status: "open", CreatedAt: day(28), Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 90}}},
+		{ID: 3, Status: "open", CreatedAt: day(2), Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 600}}},
+	}))
+	defer server.Close()
+
+	c := &conf.Config{Zendesk: conf.Zendesk{
+		Auth: conf.Auth{URL: server.URL, Email: "test@example.com", APIKey: "12345"},
+		Reports: []conf.Report{
+			{
+				Name:          AverageMetricsReport,
+				DataSet:       "reply.time",
+				Mode:          conf.AppendMode,
+				MetricOptions: conf.MetricOption{Attribute: conf.ReplyTime, Unit: conf.CalendarMetric},
+				Filter:        conf.SearchFilter{DateRange: conf.DateFilters{{Unit: "day", Past: 7}}},
+			},
+			{Name: "missing", DataSet: "missing"},
+		},
+	}}
+
+	var transport countingTransport
+	var logger memoryLogger
+	sink := &memorySink{sent: map[string]*ReportData{}}
+
+	rn := NewRunner(c, RunnerOptions{
+		HTTPClient: &http.Client{Transport: &transport},
+		Clock:      AsOf(time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)),
+		Logger:     &logger,
+		Sink:       sink,
+	})
+
+	data, err := rn.Report(&c.Zendesk.Reports[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	average, median := 60.0, 60.0
+	expected := []averageRecord{{Date: "2017-02-01", Average: &average, Median: &median, TicketCount: 2}}
+
+	if !reflect.DeepEqual(data.Records, expected) || data.Mode != conf.AppendMode || data.Schema.ID != "reply.time" {
+		t.Errorf("Expected the records of the last 7 days to 2017-02-01 %v but got %+v", expected, data)
+	}
+
+	if len(sink.sent) != 0 {
+		t.Errorf("Expected Report not to send the data but got %v", sink.sent)
+	}
+
+	if transport == 0 {
+		t.Error("Expected the Zendesk requests to be made with the HTTP client given")
+	}
+
+	expectedErr := "Processing 1 of the 2 reports failed"
+	if err := rn.Run(); err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error %q but got %v", expectedErr, err)
+	}
+
+	if _, ok := sink.sent["reply.time"]; !ok || len(sink.sent) != 1 {
+		t.Errorf("Expected Run to send the data of the report to the sink but got %v", sink.sent)
+	}
+
+	expectedLog := []string{
+		"INFO: Processing report 'reply.time' completed successfully",
+		"ERRO: Processing report 'missing' failed with: Report name missing was not found",
+		"INFO: Zendesk cache hits: search pages 1 of 2 (50%), ticket metric sets 2 of 4 (50%)",
+	}
+
+	if !reflect.DeepEqual([]string(logger), expectedLog) {
+		t.Errorf("Expected log\n%q\nbut got\n%q", expectedLog, logger)
+	}
+}
+
+func TestRunnerRateLimitRetries(t *testing.T) {
+	fake := zendesktest.New([]zendesktest.Ticket{
+		{ID: 1, Status: "open", CreatedAt: time.Date(2017, 1, 25, 10, 0, 0, 0, time.UTC)},
+	})
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := &conf.Config{Zendesk: conf.Zendesk{
+		Auth:    conf.Auth{URL: server.URL, Email: "test@example.com", APIKey: "12345"},
+		Reports: []conf.Report{{Name: TicketCountsReport, DataSet: "tickets"}},
+	}}
+
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	expectedErr := "Zendesk request failed with status 429: Number of allowed API requests per minute exceeded"
+	fake.RateLimit(1, 30)
+
+	if _, err := NewRunner(c, RunnerOptions{}).Report(&c.Zendesk.Reports[0]); err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error %q without retries but got %v", expectedErr, err)
+	}
+
+	fake.RateLimit(2, 30)
+
+	var logger memoryLogger
+	if _, err := NewRunner(c, RunnerOptions{RateLimitRetries: 2, Logger: &logger}).Report(&c.Zendesk.Reports[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLog := []string{
+		"WARN: Zendesk rate limited the request, retrying in 30s",
+		"WARN: Zendesk rate limited the request, retrying in 30s",
+	}
+
+	if !reflect.DeepEqual([]string(logger), expectedLog) {
+		t.Errorf("Expected the retries to be logged to the runner's logger\n%q\nbut got\n%q", expectedLog, logger)
+	}
+
+	if !reflect.DeepEqual(waits, []time.Duration{30 * time.Second, 30 * time.Second}) {
+		t.Errorf("Expected to wait 30s for each rate limited request but waited %v", waits)
+	}
+}
@@ -0,0 +1,239 @@
+// Package search matches tickets against Zendesk search queries, for
+// searching tickets kept outside of Zendesk such as by the fake Zendesk.
+// It supports the keys and operators the reports' filters use.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// statusOrder is the order of the statuses, which can be compared with
+// < and > such as status<solved.
+var statusOrder = []string{"new", "open", "pending", "hold", "solved", "closed"}
+
+// Ticket holds the attributes of a ticket which can be searched by. The
+// group and assignee are their names.
+type Ticket struct {
+	Type     string
+	Status   string
+	Priority string
+	Group    string
+	Assignee string
+	Tags     []string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	SolvedAt  *time.Time
+	DueAt     *time.Time
+}
+
+// UnsupportedKeyError is returned when a query searches by a key which
+// isn't supported.
+type UnsupportedKeyError struct {
+	Key string
+}
+
+func (e UnsupportedKeyError) Error() string {
+	return fmt.Sprintf("Searching by '%s' is not supported", e.Key)
+}
+
+// term is a single key, operator and value of a search query, such as
+// created>=2017-01-01. Negated terms start with a - and exclude the
+// tickets they match.
+type term struct {
+	negate   bool
+	key      string
+	operator string
+	value    string
+}
+
+// Query is a parsed search query. Terms for the same key with the :
+// operator match tickets with any of the values, as Zendesk does for
+// tags:a tags:b, and the rest must all match.
+type Query struct {
+	terms []term
+}
+
+// Parse splits the query into its terms, keeping quoted values with
+// spaces together.
+func Parse(q string) (*Query, error) {
+	var parsed Query
+
+	for _, field := range splitQuery(q) {
+		t := term{}
+		if strings.HasPrefix(field, "-") {
+			t.negate, field = true, field[1:]
+		}
+
+		i := strings.IndexAny(field, ":<>")
+		if i <= 0 {
+			return nil, fmt.Errorf("The search term '%s' has no key and operator", field)
+		}
+
+		t.key, t.operator, t.value = field[:i], field[i:i+1], field[i+1:]
+		if strings.HasPrefix(t.value, "=") && t.operator != ":" {
+			t.operator, t.value = t.operator+"=", t.value[1:]
+		}
+
+		t.value = strings.Trim(t.value, `"`)
+		if !supportedKey(t.key) {
+			return nil, UnsupportedKeyError{Key: t.key}
+		}
+
+		parsed.terms = append(parsed.terms, t)
+	}
+
+	return &parsed, nil
+}
+
+func splitQuery(q string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+
+	return fields
+}
+
+func supportedKey(key string) bool {
+	switch key {
+	case "type", "status", "priority", "ticket_type", "group", "assignee", "tags",
+		"created", "updated", "solved", "due_date":
+		return true
+	}
+
+	return false
+}
+
+// Matches returns whether the ticket matches all of the query's terms.
+func (q *Query) Matches(t *Ticket) bool {
+	anyOf := map[string]bool{}
+
+	for _, tm := range q.terms {
+		if tm.operator == ":" && !tm.negate {
+			if _, ok := anyOf[tm.key]; !ok {
+				anyOf[tm.key] = false
+			}
+
+			anyOf[tm.key] = anyOf[tm.key] || tm.matches(t)
+			continue
+		}
+
+		if tm.matches(t) == tm.negate {
+			return false
+		}
+	}
+
+	for _, matched := range anyOf {
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (tm term) matches(t *Ticket) bool {
+	switch tm.key {
+	case "type":
+		return tm.value == "ticket"
+	case "status":
+		status, value := indexOf(statusOrder, t.Status), indexOf(statusOrder, tm.value)
+		return status >= 0 && value >= 0 && compare(status, value, tm.operator)
+	case "priority":
+		return strings.EqualFold(t.Priority, tm.value)
+	case "ticket_type":
+		return strings.EqualFold(t.Type, tm.value)
+	case "group":
+		return strings.EqualFold(t.Group, tm.value)
+	case "assignee":
+		return strings.EqualFold(t.Assignee, tm.value)
+	case "tags":
+		return indexOf(t.Tags, tm.value) >= 0
+	case "created":
+		return compareDate(&t.CreatedAt, tm.value, tm.operator)
+	case "updated":
+		return compareDate(&t.UpdatedAt, tm.value, tm.operator)
+	case "solved":
+		return compareDate(t.SolvedAt, tm.value, tm.operator)
+	case "due_date":
+		return compareDate(t.DueAt, tm.value, tm.operator)
+	}
+
+	return false
+}
+
+// compareDate compares the day of the date with the value, which can be a
+// day or a time. Tickets without the date never match.
+func compareDate(date *time.Time, value, operator string) bool {
+	if date == nil || date.IsZero() {
+		return false
+	}
+
+	if len(value) > len(dateFormat) {
+		v, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return false
+		}
+
+		switch {
+		case date.Before(v):
+			return compare(-1, 0, operator)
+		case date.After(v):
+			return compare(1, 0, operator)
+		}
+
+		return compare(0, 0, operator)
+	}
+
+	return compare(strings.Compare(date.UTC().Format(dateFormat), value), 0, operator)
+}
+
+func compare(a, b int, operator string) bool {
+	switch operator {
+	case ":":
+		return a == b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+
+	return false
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if strings.EqualFold(v, value) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryMatches(t *testing.T) {
+	solved := time.Date(2017, 1, 20, 12, 0, 0, 0, time.UTC)
+	ticket := Ticket{
+		Type:      "incident",
+		Status:    "pending",
+		Priority:  "high",
+		Group:     "Support",
+		Tags:      []string{"beta", "vip"},
+		CreatedAt: time.Date(2017, 1, 10, 9, 30, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2017, 1, 21, 9, 30, 0, 0, time.UTC),
+		SolvedAt:  &solved,
+	}
+
+	testCases := []struct {
+		query   string
+		matches bool
+		err     string
+	}{
+		{query: "type:ticket", matches: true},
+		{query: "type:user", matches: false},
+		{query: "type:ticket status:pending priority:high", matches: true},
+		{query: "type:ticket status:open status:pending", matches: true},
+		{query: "type:ticket status:open priority:high", matches: false},
+		{query: "status<solved", matches: true},
+		{query: "status>=solved", matches: false},
+		{query: "tags:freetrial tags:vip", matches: true},
+		{query: "tags:freetrial", matches: false},
+		{query: "-tags:vip", matches: false},
+		{query: "-tags:freetrial ticket_type:incident", matches: true},
+		{query: `group:"support"`, matches: true},
+		{query: "created>=2017-01-10 created<2017-01-11", matches: true},
+		{query: "created>2017-01-10", matches: false},
+		{query: "created:2017-01-10", matches: true},
+		{query: "created>2017-01-10T09:00:00Z", matches: true},
+		{query: "solved<2017-01-21", matches: true},
+		{query: "due_date>2017-01-01", matches: false},
+		{query: "organization:acme", err: "Searching by 'organization' is not supported"},
+		{query: "beta", err: "The search term 'beta' has no key and operator"},
+	}
+
+	for i, tc := range testCases {
+		q, err := Parse(tc.query)
+
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+			continue
+		}
+
+		if q.Matches(&ticket) != tc.matches {
+			t.Errorf("[spec %d] Expected %q to match %t but got %t", i, tc.query, tc.matches, !tc.matches)
+		}
+	}
+}
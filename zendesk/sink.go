@@ -0,0 +1,99 @@
+package zendesk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+)
+
+// geckoboardSink sends the data of each report to its dataset in the
+// report's Geckoboard account.
+type geckoboardSink struct {
+	config    *conf.Config
+	transport http.RoundTripper
+	logger    Logger
+}
+
+// NewGeckoboardSink returns a sink sending the data of each report to its
+// Geckoboard account, creating the dataset when it doesn't exist. The
+// requests are sent with the transport, or http.DefaultTransport when it is
+// nil, using each account's timeout and retries.
+func NewGeckoboardSink(c *conf.Config, transport http.RoundTripper, logger Logger) Sink {
+	return &geckoboardSink{config: c, transport: transport, logger: logger}
+}
+
+func (s *geckoboardSink) String() string {
+	return "Geckoboard"
+}
+
+func (s *geckoboardSink) Send(r *conf.Report, data *ReportData) error {
+	account, err := s.config.GeckoboardFor(r)
+	if err != nil {
+		return err
+	}
+
+	//Create the dataset schema
+	gConf := NewGeckoboardClient(account, s.transport)
+
+	schema := data.Schema
+
+	err = schema.FindOrCreate(gConf)
+	if mErr, ok := err.(gb.SchemaMismatchError); ok {
+		if !r.RecreateOnSchemaChange {
+			return fmt.Errorf("%s, set recreate_on_schema_change to delete and recreate it", mErr.Error())
+		}
+
+		s.logger.Printf("WARN: Recreating dataset '%s' as the schema changed: %s", schema.ID, mErr.Error())
+
+		if err = schema.Delete(gConf); err != nil {
+			return err
+		}
+
+		err = schema.FindOrCreate(gConf)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch data.Mode {
+	case conf.AppendMode:
+		err = schema.Append(gConf, data.Records, dateField)
+	default:
+		err = schema.SendAll(gConf, data.Records)
+	}
+
+	return err
+}
+
+// fileSink writes the records of each report as a line of JSON.
+type fileSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileSink returns a sink writing the records of each report to w as a
+// line of JSON along with the report's dataset, such as to keep them in a
+// file rather than sending them to Geckoboard.
+func NewFileSink(w io.Writer) Sink {
+	return &fileSink{w: w}
+}
+
+func (s *fileSink) String() string {
+	return "a file"
+}
+
+func (s *fileSink) Send(r *conf.Report, data *ReportData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return json.NewEncoder(s.w).Encode(struct {
+		DataSet string      `json:"dataset"`
+		Records interface{} `json:"records"`
+	}{r.DataSet, data.Records})
+}
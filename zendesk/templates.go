@@ -0,0 +1,136 @@
+package zendesk
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+	"gopkg.in/yaml.v3"
+)
+
+// Template builds the records of a kind of report, named by the report's
+// name option, from the tickets it searches for. Templates are registered
+// with RegisterTemplate, so they can be added from outside the package.
+type Template interface {
+	// Help describes the template for the generated help.
+	Help() TemplateHelp
+
+	// Validate checks the options of the report only the template uses,
+	// such as its metric options, without making any requests.
+	Validate(r *conf.Report) error
+
+	// Fetch searches for the tickets the report's records are built from,
+	// with the report's date ranges relative to now.
+	Fetch(r *conf.Report, s TicketSearcher, now time.Time) ([]TicketGroup, error)
+
+	// Schema returns the dataset the records are sent to in the mode, in
+	// append mode including the date field the records are unique by.
+	Schema(r *conf.Report, mode conf.ReportMode) *gb.DataSet
+
+	// Records builds the records sent to the dataset from the groups of
+	// tickets fetched, date being the day of the run in append mode and
+	// empty otherwise.
+	Records(r *conf.Report, groups []TicketGroup, date string) interface{}
+}
+
+// Explainer is implemented by templates which make other searches than
+// the one built from the report's filter, so explain can print them.
+type Explainer interface {
+	Explain(r *conf.Report, now time.Time) ([]string, error)
+}
+
+// TicketGroup is what a template fetched for one of the groups of a
+// report, or for the whole report when it isn't grouped: the number of
+// tickets found and, when the template needs them, the tickets.
+type TicketGroup struct {
+	Name    string
+	Count   int
+	Tickets []Ticket
+}
+
+// TemplateHelp describes a template. The example is a report using the
+// template as it is written in the config, without the leading dash.
+type TemplateHelp struct {
+	Title   string
+	Summary string
+	Example string
+}
+
+var (
+	templates     = map[string]Template{}
+	templateNames []string
+)
+
+// RegisterTemplate makes the template available to reports with the name
+// and makes the name and the template's checks known to conf.Validate. It
+// panics if a template is already registered with the name.
+func RegisterTemplate(name string, t Template) {
+	if _, ok := templates[name]; ok {
+		panic(fmt.Sprintf("zendesk: template %s is already registered", name))
+	}
+
+	templates[name] = t
+	templateNames = append(templateNames, name)
+	conf.RegisterReport(name, t.Validate)
+}
+
+// LookupTemplate returns the template registered with the name.
+func LookupTemplate(name string) (Template, error) {
+	t, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("Report name %s was not found", name)
+	}
+
+	return t, nil
+}
+
+// TemplateNames returns the names of the registered templates in the
+// order they were registered.
+func TemplateNames() []string {
+	return append([]string(nil), templateNames...)
+}
+
+// WriteTemplateHelp writes the help for each of the registered templates,
+// in the markdown of doc/supported_reports.md, with the fields of the
+// dataset the template's example report sends to.
+func WriteTemplateHelp(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# Supported Reports\n\nSupported Report Templates;\n\n")
+	for _, name := range templateNames {
+		title := templates[name].Help().Title
+		fmt.Fprintf(&b, "* [%s](#%s)\n", title, strings.Replace(strings.ToLower(title), " ", "-", -1))
+	}
+
+	for _, name := range templateNames {
+		help := templates[name].Help()
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", help.Title, strings.TrimSpace(help.Summary))
+
+		var r conf.Report
+		if err := yaml.Unmarshal([]byte(help.Example), &r); err != nil {
+			return fmt.Errorf("Example of template %s is not valid: %s", name, err.Error())
+		}
+
+		b.WriteString("\n| Field | Type |\n| --- | --- |\n")
+
+		schema := templates[name].Schema(&r, conf.ReplaceMode)
+		ids := make([]string, 0, len(schema.Fields))
+		for id := range schema.Fields {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			fmt.Fprintf(&b, "| %s | %s |\n", schema.Fields[id].Name, schema.Fields[id].Type)
+		}
+
+		fmt.Fprintf(&b, "\n```yaml\n  - %s\n```\n", strings.Replace(strings.TrimSpace(help.Example), "\n", "\n    ", -1))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,178 @@
+package zendesk
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	gb "github.com/geckoboard/zendesk_dataset/geckoboard"
+	"gopkg.in/yaml.v3"
+)
+
+// tagCounts is a template as it would be written outside the package,
+// counting the tickets the filter finds with each tag.
+type tagCounts struct{}
+
+type tagCountRecord struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+func (tagCounts) Help() TemplateHelp {
+	return TemplateHelp{
+		Title:   "Tag counts",
+		Summary: "Counts the tickets with each tag.",
+		Example: "name: tag_counts\ndataset: tickets.by.each.tag",
+	}
+}
+
+func (tagCounts) Validate(r *conf.Report) error {
+	if r.GroupBy.Key != "" {
+		return errors.New("Tag counts can't be grouped")
+	}
+
+	return nil
+}
+
+func (tagCounts) Fetch(r *conf.Report, s TicketSearcher, now time.Time) ([]TicketGroup, error) {
+	tp, err := s.SearchTickets(&Query{Params: r.Filter.BuildQuery(&now)})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, t := range tp.Tickets {
+		for _, tag := range t.Tags {
+			counts[tag]++
+		}
+	}
+
+	var groups []TicketGroup
+	for tag, count := range counts {
+		groups = append(groups, TicketGroup{Name: tag, Count: count})
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+func (tagCounts) Schema(r *conf.Report, mode conf.ReportMode) *gb.DataSet {
+	return &gb.DataSet{
+		ID: r.DataSet,
+		Fields: gb.Fields{
+			"tag":   gb.Field{Type: gb.StringFieldType, Name: "Tag"},
+			"count": gb.Field{Type: gb.NumberFieldType, Name: "Count"},
+		},
+	}
+}
+
+func (tagCounts) Records(r *conf.Report, groups []TicketGroup, date string) interface{} {
+	var records []tagCountRecord
+	for _, g := range groups {
+		records = append(records, tagCountRecord{Tag: g.Name, Count: g.Count})
+	}
+
+	return records
+}
+
+func init() {
+	RegisterTemplate("tag_counts", tagCounts{})
+}
+
+func TestRegisteredTemplate(t *testing.T) {
+	fakes := newEndToEndFakes()
+	defer fakes.close()
+
+	c := loadEndToEndConfig(t, fakes.urls())
+	c.Zendesk.Reports = []conf.Report{{
+		Name:    "tag_counts",
+		DataSet: "tickets.by.each.tag",
+		Filter:  conf.SearchFilter{DateRange: conf.DateFilters{{Unit: "day", Past: 30}}},
+	}}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected the registered template to be valid but got %s", err)
+	}
+
+	clock := AsOf(time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC))
+	NewRunner(c, RunnerOptions{Clock: clock}).Run()
+
+	expected := []gb.Record{
+		{"tag": "beta", "count": float64(2)},
+		{"tag": "vip", "count": float64(2)},
+	}
+
+	if records := fakes.geckoboard.Records("tickets.by.each.tag"); !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected records %v but got %v", expected, records)
+	}
+}
+
+func TestRegisterTemplateTwice(t *testing.T) {
+	defer func() {
+		expected := "zendesk: template ticket_counts is already registered"
+		if r := recover(); r != expected {
+			t.Errorf("Expected panic %q but got %v", expected, r)
+		}
+	}()
+
+	RegisterTemplate(TicketCountsReport, ticketCounts{})
+}
+
+func TestLookupTemplate(t *testing.T) {
+	if _, err := LookupTemplate(AverageMetricsReport); err != nil {
+		t.Errorf("Expected the average metrics template but got %s", err)
+	}
+
+	_, err := LookupTemplate("missing")
+	if err == nil || err.Error() != "Report name missing was not found" {
+		t.Errorf("Expected a not found error but got %v", err)
+	}
+}
+
+func TestTemplateExamplesAreValid(t *testing.T) {
+	for _, name := range TemplateNames() {
+		tmpl, _ := LookupTemplate(name)
+
+		var r conf.Report
+		if err := yaml.Unmarshal([]byte(tmpl.Help().Example), &r); err != nil {
+			t.Errorf("Template %s example is not YAML: %s", name, err)
+			continue
+		}
+
+		if r.Name != name {
+			t.Errorf("Expected template %s example to use it but got %s", name, r.Name)
+		}
+
+		if errs := r.Validate(); len(errs) > 0 {
+			t.Errorf("Template %s example is not valid: %v", name, errs)
+		}
+	}
+}
+
+func TestWriteTemplateHelp(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteTemplateHelp(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	help := b.String()
+
+	expected := []string{
+		"* [Ticket counts](#ticket-counts)\n* [Ticket counts by day](#ticket-counts-by-day)\n",
+		"## Average ticket metrics\n",
+		"| Average reply time | duration |\n",
+		"## Tag counts\n\nCounts the tickets with each tag.\n\n| Field | Type |\n| --- | --- |\n| Count | number |\n| Tag | string |\n",
+		"```yaml\n  - name: tag_counts\n    dataset: tickets.by.each.tag\n```\n",
+	}
+
+	for i, e := range expected {
+		if !strings.Contains(help, e) {
+			t.Errorf("[spec %d] Expected the help to contain %q but got\n%s", i, e, help)
+		}
+	}
+}
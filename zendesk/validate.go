@@ -0,0 +1,23 @@
+package zendesk
+
+import (
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+// ExplainReport returns the Zendesk search queries the report makes as of
+// now, those of its template when it is an Explainer, such as grouped
+// ticket counts which make a query for each group prefixed with the group.
+func ExplainReport(r *conf.Report, now time.Time) ([]string, error) {
+	t, err := LookupTemplate(r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if e, ok := t.(Explainer); ok {
+		return e.Explain(r, now)
+	}
+
+	return []string{r.Filter.BuildQuery(&now)}, nil
+}
@@ -0,0 +1,137 @@
+package zendesk
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+)
+
+// TestReportValidate checks the report's validation runs the checks of the
+// templates registered by this package.
+func TestReportValidate(t *testing.T) {
+	testCases := []struct {
+		report conf.Report
+		err    string
+	}{
+		{
+			report: conf.Report{Name: TicketCountsReport, DataSet: "tickets"},
+		},
+		{
+			report: conf.Report{Name: TicketCountsReport},
+			err:    "Report is missing the dataset to send the data to",
+		},
+		{
+			report: conf.Report{Name: "ticket_count", DataSet: "tickets"},
+			err:    "Report name ticket_count was not found",
+		},
+		{
+			report: conf.Report{Name: TicketCountsByDayReport, DataSet: "tickets", Mode: "upsert"},
+			err:    "Report mode 'upsert' is not valid must be one of [replace append]",
+		},
+		{
+			report: conf.Report{
+				Name:    TicketCountsReport,
+				DataSet: "tickets",
+				GroupBy: conf.GroupBy{Key: "tags:"},
+			},
+			err: "Group by values key 'tags:' returned no values to group by",
+		},
+		{
+			report: conf.Report{
+				Name:    TicketCountsReport,
+				DataSet: "tickets",
+				Filter:  conf.SearchFilter{DateRange: conf.DateFilters{{Custom: "2016-01-01"}}},
+			},
+			err: "Custom input requires the operator one of [< : >]",
+		},
+		{
+			report: conf.Report{Name: DetailedMetricsReport, DataSet: "tickets"},
+			err:    conf.ErrEmptyMetricOptions.Error(),
+		},
+		{
+			report: conf.Report{
+				Name:          DetailedMetricsReport,
+				DataSet:       "tickets",
+				MetricOptions: conf.MetricOption{Attribute: conf.ReplyTime, Unit: conf.BusinessMetric},
+			},
+			err: conf.ErrEmptyGrouping.Error(),
+		},
+		{
+			report: conf.Report{
+				Name:          AverageMetricsReport,
+				DataSet:       "tickets",
+				MetricOptions: conf.MetricOption{Attribute: conf.ReplyTime, Unit: conf.BusinessMetric},
+			},
+		},
+		{
+			report: conf.Report{
+				Name:          AverageMetricsReport,
+				DataSet:       "tickets",
+				MetricOptions: conf.MetricOption{Attribute: conf.ReplyTime, Unit: conf.BusinessMetric, Percentage: true},
+			},
+			err: conf.ErrPercentageNotGrouped.Error(),
+		},
+	}
+
+	for i, tc := range testCases {
+		errs := tc.report.Validate()
+
+		if tc.err == "" && len(errs) > 0 {
+			t.Errorf("[spec %d] Unexpected errors got %v", i, errs)
+		}
+
+		if tc.err != "" && (len(errs) != 1 || errs[0].Error() != tc.err) {
+			t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, errs)
+		}
+	}
+}
+
+func TestExplainReport(t *testing.T) {
+	now := time.Date(2016, 06, 01, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		report conf.Report
+		out    []string
+	}{
+		{
+			report: conf.Report{
+				Name:   TicketCountsByDayReport,
+				Filter: conf.SearchFilter{DateRange: conf.DateFilters{{Unit: "day", Past: 7}}},
+			},
+			out: []string{"type:ticket created>=2016-05-25"},
+		},
+		{
+			report: conf.Report{
+				Name:    TicketCountsReport,
+				GroupBy: conf.GroupBy{Key: "tags:"},
+				Filter: conf.SearchFilter{
+					Value:  map[string]string{"status:": "open"},
+					Values: map[string][]string{"tags:": []string{"beta", "free trial"}},
+				},
+			},
+			out: []string{
+				"beta: type:ticket status:open tags:beta",
+				`free trial: type:ticket status:open tags:"free trial"`,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		out, err := ExplainReport(&tc.report, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("[spec %d] Expected %q but got %q", i, tc.out, out)
+		}
+	}
+
+	// Explaining a grouped report must leave its filter values as they were.
+	values := testCases[1].report.Filter.Values["tags:"]
+	if len(values) != 2 {
+		t.Errorf("Expected the group by values to be unchanged but got %v", values)
+	}
+}
@@ -0,0 +1,541 @@
+package zendesk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk/search"
+)
+
+const (
+	exportPath = "/incremental/tickets/cursor.json"
+
+	// exportIncludes sideloads the metric sets, and the users and groups
+	// so the tickets can be searched by assignee and group name.
+	exportIncludes = "metric_sets,users,groups"
+
+	// maxExportRetries is how many times a rate limited export is retried,
+	// Zendesk only allows 10 exports a minute.
+	maxExportRetries = 5
+)
+
+// warehouse is the local copy of the tickets and their metric sets from
+// each Zendesk account, kept in a JSON file and brought up to date with the
+// incremental ticket export, so reports can search it rather than Zendesk.
+//
+// Rewriting the whole file after each page of the export would take longer
+// with each page, so a sync appends the pages to a journal next to the file
+// instead and only rewrites the file once it is done. Opening the warehouse
+// applies any pages left in the journal by an interrupted sync.
+type warehouse struct {
+	Accounts map[string]*warehouseAccount `json:"accounts"`
+
+	path    string
+	journal *os.File
+}
+
+// journalEntry is a page of the export of an account kept in the journal.
+type journalEntry struct {
+	Account  string      `json:"account"`
+	Page     *exportPage `json:"page"`
+	SyncedAt *time.Time  `json:"synced_at,omitempty"`
+}
+
+// warehouseAccount holds the tickets of an account and the cursor the
+// next sync continues the export from.
+type warehouseAccount struct {
+	Cursor   string                   `json:"cursor"`
+	SyncedAt time.Time                `json:"synced_at"`
+	Tickets  map[int]*warehouseTicket `json:"tickets"`
+	Groups   map[int64]string         `json:"groups"`
+	Users    map[int64]string         `json:"users"`
+}
+
+// warehouseTicket is a ticket with the attributes reports can search by
+// and its metric set.
+type warehouseTicket struct {
+	ID       int      `json:"id"`
+	Type     string   `json:"type"`
+	Status   string   `json:"status"`
+	Priority string   `json:"priority"`
+	Group    string   `json:"group"`
+	Assignee string   `json:"assignee"`
+	Tags     []string `json:"tags"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	SolvedAt  *time.Time `json:"solved_at,omitempty"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+
+	Metrics MetricSet `json:"metric_set"`
+}
+
+// exportPage is a page of the incremental ticket export with its sideloads.
+type exportPage struct {
+	Tickets []struct {
+		ID         int        `json:"id"`
+		Type       string     `json:"type"`
+		Status     string     `json:"status"`
+		Priority   string     `json:"priority"`
+		GroupID    *int64     `json:"group_id"`
+		AssigneeID *int64     `json:"assignee_id"`
+		Tags       []string   `json:"tags"`
+		CreatedAt  time.Time  `json:"created_at"`
+		UpdatedAt  time.Time  `json:"updated_at"`
+		DueAt      *time.Time `json:"due_at"`
+	} `json:"tickets"`
+	MetricSets []struct {
+		TicketID int        `json:"ticket_id"`
+		SolvedAt *time.Time `json:"solved_at"`
+		MetricSet
+	} `json:"metric_sets"`
+	Users       []named `json:"users"`
+	Groups      []named `json:"groups"`
+	AfterCursor string  `json:"after_cursor"`
+	EndOfStream bool    `json:"end_of_stream"`
+}
+
+type named struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// openWarehouse reads the warehouse kept at the path, a missing file being
+// an empty warehouse.
+func openWarehouse(path string) (*warehouse, error) {
+	w := &warehouse{Accounts: map[string]*warehouseAccount{}, path: path}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return w, w.replayJournal()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Reading the Zendesk warehouse failed with: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(b, w); err != nil {
+		return nil, fmt.Errorf("Zendesk warehouse %s is not valid: %s", path, err.Error())
+	}
+
+	if w.Accounts == nil {
+		w.Accounts = map[string]*warehouseAccount{}
+	}
+
+	return w, w.replayJournal()
+}
+
+func (w *warehouse) journalPath() string {
+	return w.path + ".journal"
+}
+
+// replayJournal applies the pages an interrupted sync left in the journal.
+// A page cut short by the interruption is left out, and the sync fetches
+// it again from the cursor before it.
+func (w *warehouse) replayJournal() error {
+	f, err := os.Open(w.journalPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("Reading the Zendesk warehouse journal failed with: %s", err.Error())
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var e journalEntry
+		if err := dec.Decode(&e); err != nil || e.Page == nil {
+			return nil
+		}
+
+		w.account(e.Account).applyPage(e.Page, e.SyncedAt)
+	}
+}
+
+// appendJournal adds the page of the account's export to the journal.
+func (w *warehouse) appendJournal(e *journalEntry) error {
+	if w.journal == nil {
+		f, err := os.OpenFile(w.journalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("Opening the Zendesk warehouse journal failed with: %s", err.Error())
+		}
+
+		w.journal = f
+	}
+
+	if err := json.NewEncoder(w.journal).Encode(e); err != nil {
+		return fmt.Errorf("Writing the Zendesk warehouse journal failed with: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (w *warehouse) closeJournal() {
+	if w.journal != nil {
+		w.journal.Close()
+		w.journal = nil
+	}
+}
+
+// save writes the warehouse to its file, leaving the last one whole if
+// the save is interrupted, and then removes the journal it now holds.
+func (w *warehouse) save() error {
+	b, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceFile(w.path, b); err != nil {
+		return fmt.Errorf("Saving the Zendesk warehouse failed with: %s", err.Error())
+	}
+
+	w.closeJournal()
+
+	if err := os.Remove(w.journalPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Removing the Zendesk warehouse journal failed with: %s", err.Error())
+	}
+
+	return nil
+}
+
+// replaceFile writes the contents to a new file before replacing the file
+// at the path with it, so an interrupted write leaves the old one whole.
+func replaceFile(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+func (w *warehouse) account(name string) *warehouseAccount {
+	a, ok := w.Accounts[name]
+	if !ok {
+		a = &warehouseAccount{}
+		w.Accounts[name] = a
+	}
+
+	if a.Tickets == nil {
+		a.Tickets = map[int]*warehouseTicket{}
+	}
+
+	if a.Groups == nil {
+		a.Groups = map[int64]string{}
+	}
+
+	if a.Users == nil {
+		a.Users = map[int64]string{}
+	}
+
+	return a
+}
+
+// SyncWarehouse copies the tickets created or changed since the last sync,
+// along with their metric sets, from each Zendesk account the reports use
+// to the warehouse. Each page of tickets is added to the warehouse's journal
+// so an interrupted sync continues from where it stopped. The requests are made
+// with the HTTP client, or one using each account's timeout, proxy and CA
+// certificates when it is nil, and the progress is logged to the logger, or
+// the standard logger when it is nil.
+func SyncWarehouse(c *conf.Config, httpClient *http.Client, logger Logger) error {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	if c.Zendesk.Warehouse.Path == "" {
+		return errors.New("The zendesk warehouse has no path to keep the tickets in")
+	}
+
+	start, err := c.Zendesk.Warehouse.StartTime()
+	if err != nil {
+		return err
+	}
+
+	w, err := openWarehouse(c.Zendesk.Warehouse.Path)
+	if err != nil {
+		return err
+	}
+	defer w.closeJournal()
+
+	names := c.UsedZendeskAccounts()
+	if len(names) == 0 {
+		names = []string{conf.DefaultAccount}
+	}
+
+	for _, n := range names {
+		auths, err := c.ZendeskAuths(&conf.Report{ZendeskAccounts: []string{n}})
+		if err != nil {
+			return err
+		}
+
+		client, err := NewClient(auths[0], httpClient)
+		if err != nil {
+			return err
+		}
+
+		client.PaginateResults = false
+		client.rateLimitRetries = maxExportRetries
+		client.logger = logger
+
+		if err := w.sync(client, start); err != nil {
+			return fmt.Errorf("Syncing Zendesk account '%s' failed with: %s", client.account(), err.Error())
+		}
+	}
+
+	return nil
+}
+
+// sync exports the tickets of the client's account changed since its
+// cursor, or since the start time when it has never been synced.
+func (w *warehouse) sync(client *Client, start time.Time) error {
+	a := w.account(client.account())
+	changed := 0
+
+	for {
+		page, err := client.exportTickets(a.Cursor, start)
+		if err != nil {
+			return err
+		}
+
+		if page.AfterCursor == "" && !page.EndOfStream {
+			return errors.New("Zendesk's export has more tickets but no cursor to them")
+		}
+
+		e := &journalEntry{Account: client.account(), Page: page}
+		if page.EndOfStream {
+			now := time.Now().UTC()
+			e.SyncedAt = &now
+		}
+
+		if err := w.appendJournal(e); err != nil {
+			return err
+		}
+
+		a.applyPage(page, e.SyncedAt)
+		changed += len(page.Tickets)
+
+		if page.EndOfStream {
+			break
+		}
+	}
+
+	if err := w.save(); err != nil {
+		return err
+	}
+
+	client.logger.Printf("INFO: Synced %d changed tickets from Zendesk account '%s', the warehouse has %d of its tickets",
+		changed, client.account(), len(a.Tickets))
+
+	return nil
+}
+
+// exportTickets requests a page of the incremental ticket export.
+func (c *Client) exportTickets(cursor string, start time.Time) (*exportPage, error) {
+	params := map[string]string{"include": exportIncludes}
+
+	switch {
+	case cursor != "":
+		params["cursor"] = cursor
+	case start.IsZero():
+		params["start_time"] = "0"
+	default:
+		params["start_time"] = strconv.FormatInt(start.Unix(), 10)
+	}
+
+	url, err := c.buildURL(&Query{Endpoint: exportPath, ExtraParams: params})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.buildRequest("GET", url)
+	if err != nil {
+		return nil, err
+	}
+
+	var page exportPage
+	if err := c.doRequest(req, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// applyPage applies the page and moves the cursor on past it, recording
+// when the account was synced once the page ends the export.
+func (a *warehouseAccount) applyPage(page *exportPage, syncedAt *time.Time) {
+	a.apply(page)
+
+	if page.AfterCursor != "" {
+		a.Cursor = page.AfterCursor
+	}
+
+	if syncedAt != nil {
+		a.SyncedAt = *syncedAt
+	}
+}
+
+// apply adds or replaces the tickets of the page, removing those which
+// have been deleted.
+func (a *warehouseAccount) apply(page *exportPage) {
+	for _, g := range page.Groups {
+		a.Groups[g.ID] = g.Name
+	}
+
+	for _, u := range page.Users {
+		a.Users[u.ID] = u.Name
+	}
+
+	for _, t := range page.Tickets {
+		if t.Status == "deleted" {
+			delete(a.Tickets, t.ID)
+			continue
+		}
+
+		wt := &warehouseTicket{
+			ID:        t.ID,
+			Type:      t.Type,
+			Status:    t.Status,
+			Priority:  t.Priority,
+			Tags:      t.Tags,
+			CreatedAt: t.CreatedAt,
+			UpdatedAt: t.UpdatedAt,
+			DueAt:     t.DueAt,
+		}
+
+		if t.GroupID != nil {
+			wt.Group = a.Groups[*t.GroupID]
+		}
+
+		if t.AssigneeID != nil {
+			wt.Assignee = a.Users[*t.AssigneeID]
+		}
+
+		// Keep the metric set the ticket had if the page has none for it.
+		if old, ok := a.Tickets[t.ID]; ok {
+			wt.Metrics, wt.SolvedAt = old.Metrics, old.SolvedAt
+		}
+
+		a.Tickets[t.ID] = wt
+	}
+
+	for _, m := range page.MetricSets {
+		if t, ok := a.Tickets[m.TicketID]; ok {
+			t.Metrics, t.SolvedAt = m.MetricSet, m.SolvedAt
+		}
+	}
+}
+
+// warehouseSearcher searches the tickets of one or more accounts in the
+// warehouse in place of Zendesk.
+type warehouseSearcher struct {
+	warehouse *warehouse
+	accounts  []string
+}
+
+// CountTickets returns the number of tickets matching the query.
+func (ws warehouseSearcher) CountTickets(q *Query) (int, error) {
+	tickets, err := ws.search(q)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(tickets), nil
+}
+
+// SearchTickets returns all the tickets matching the query, as the
+// warehouse has no need to page through them.
+func (ws warehouseSearcher) SearchTickets(q *Query) (*TicketPayload, error) {
+	tickets, err := ws.search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TicketPayload{Count: len(tickets), Tickets: tickets}, nil
+}
+
+// TicketMetrics returns the tickets matching the query with their metrics.
+func (ws warehouseSearcher) TicketMetrics(q *Query) (*TicketMetrics, error) {
+	tickets, err := ws.search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TicketMetrics{Count: len(tickets), Tickets: tickets}, nil
+}
+
+func (ws warehouseSearcher) search(q *Query) ([]Ticket, error) {
+	sq, err := search.Parse(q.Params)
+	if e, ok := err.(search.UnsupportedKeyError); ok {
+		err = fmt.Errorf("The warehouse can't search by '%s', use the search source for this report", e.Key)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tickets []Ticket
+
+	for _, name := range ws.accounts {
+		a, ok := ws.warehouse.Accounts[name]
+		if !ok || a.SyncedAt.IsZero() {
+			return nil, fmt.Errorf("Zendesk account '%s' hasn't been synced to the warehouse, run the sync command first", name)
+		}
+
+		// Search the tickets in order so the results are always the same.
+		ids := make([]int, 0, len(a.Tickets))
+		for id := range a.Tickets {
+			ids = append(ids, id)
+		}
+
+		sort.Ints(ids)
+		for _, id := range ids {
+			t := a.Tickets[id]
+			if sq.Matches(t.searchable()) {
+				tickets = append(tickets, Ticket{ID: t.ID, Tags: t.Tags, Metrics: t.Metrics, CreatedAt: t.CreatedAt})
+			}
+		}
+	}
+
+	return tickets, nil
+}
+
+func (t *warehouseTicket) searchable() *search.Ticket {
+	return &search.Ticket{
+		Type:      t.Type,
+		Status:    t.Status,
+		Priority:  t.Priority,
+		Group:     t.Group,
+		Assignee:  t.Assignee,
+		Tags:      t.Tags,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		SolvedAt:  t.SolvedAt,
+		DueAt:     t.DueAt,
+	}
+}
@@ -0,0 +1,198 @@
+package zendesk
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/conf"
+	"github.com/geckoboard/zendesk_dataset/zendesk/zendesktest"
+)
+
+func TestSyncWarehouse(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 10, 0, 0, 0, time.UTC) }
+	solved := day(4)
+
+	fake := zendesktest.New([]zendesktest.Ticket{
+		{ID: 1, Status: "open", Group: "Support", Tags: []string{"beta"}, CreatedAt: day(1), UpdatedAt: day(2)},
+		{ID: 2, Status: "solved", Assignee: "Ann", CreatedAt: day(1), UpdatedAt: day(4), SolvedAt: &solved,
+			Metrics: zendesktest.MetricSet{ReplyTime: zendesktest.Metric{Calendar: 45}}},
+		{ID: 3, Status: "pending", CreatedAt: day(3), UpdatedAt: day(3)},
+	})
+	fake.PageSize = 2
+
+	// Rate limit and then fail the request for the second page.
+	served := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+
+		switch served {
+		case 2:
+			fake.RateLimit(1, 30)
+		case 3:
+			fake.Fail(1, http.StatusInternalServerError, "Something went wrong")
+		}
+
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "zendesk_warehouse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &conf.Config{Zendesk: conf.Zendesk{
+		Auth:      conf.Auth{URL: server.URL, Email: "test@example.com", APIKey: "12345"},
+		Warehouse: conf.Warehouse{Path: filepath.Join(dir, "warehouse.json")},
+	}}
+
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	if err := SyncWarehouse(c, nil, nil); err == nil || !strings.Contains(err.Error(), "Something went wrong") {
+		t.Errorf("Expected the sync to fail but got %v", err)
+	}
+
+	w, err := openWarehouse(c.Zendesk.Warehouse.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a := w.Accounts[server.URL]; a == nil || len(a.Tickets) != 2 || !a.SyncedAt.IsZero() {
+		t.Errorf("Expected the first page to have been saved before the sync failed but got %+v", a)
+	}
+
+	// The next sync continues from the page which failed.
+	var logger memoryLogger
+	if err := SyncWarehouse(c, nil, &logger); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLog := []string{
+		"INFO: Synced 1 changed tickets from Zendesk account '" + server.URL + "', the warehouse has 3 of its tickets",
+	}
+
+	if !reflect.DeepEqual([]string(logger), expectedLog) {
+		t.Errorf("Expected the sync to be logged to the logger\n%q\nbut got\n%q", expectedLog, logger)
+	}
+
+	if _, err := os.Stat(c.Zendesk.Warehouse.Path + ".journal"); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be removed once the sync was saved but got %v", err)
+	}
+
+	if !reflect.DeepEqual(waits, []time.Duration{30 * time.Second}) {
+		t.Errorf("Expected to wait 30s when rate limited but waited %v", waits)
+	}
+
+	expected := []string{
+		"/api/v2/incremental/tickets/cursor.json?include=metric_sets%2Cusers%2Cgroups&start_time=0",
+		"/api/v2/incremental/tickets/cursor.json?cursor=1483437600.3&include=metric_sets%2Cusers%2Cgroups",
+		"/api/v2/incremental/tickets/cursor.json?cursor=1483437600.3&include=metric_sets%2Cusers%2Cgroups",
+		"/api/v2/incremental/tickets/cursor.json?cursor=1483437600.3&include=metric_sets%2Cusers%2Cgroups",
+	}
+
+	if requests := fake.Requests(); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Expected requests\n%v\nbut got\n%v", expected, requests)
+	}
+
+	// Syncing again only fetches the changes since.
+	fake.Update(
+		zendesktest.Ticket{ID: 3, Status: "deleted", CreatedAt: day(3), UpdatedAt: day(5)},
+		zendesktest.Ticket{ID: 4, Status: "new", Tags: []string{"beta"}, CreatedAt: day(6), UpdatedAt: day(6)},
+	)
+
+	if err := SyncWarehouse(c, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if w, err = openWarehouse(c.Zendesk.Warehouse.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	a := w.Accounts[server.URL]
+	if a == nil || a.SyncedAt.IsZero() {
+		t.Fatalf("Expected the account to have been synced but got %+v", a)
+	}
+
+	ids := []int{}
+	for id := range a.Tickets {
+		ids = append(ids, id)
+	}
+
+	if len(ids) != 3 || a.Tickets[3] != nil {
+		t.Errorf("Expected tickets 1, 2 and 4 with the deleted ticket removed but got %v", ids)
+	}
+
+	if a.Tickets[1].Group != "Support" || a.Tickets[2].Assignee != "Ann" {
+		t.Errorf("Expected the group and assignee names but got %+v and %+v", a.Tickets[1], a.Tickets[2])
+	}
+
+	if replyTime := a.Tickets[2].Metrics.ReplyTime.Calendar; replyTime == nil || *replyTime != 45 || a.Tickets[2].SolvedAt == nil {
+		t.Errorf("Expected the metric set of ticket 2 but got %+v", a.Tickets[2])
+	}
+
+	ws := warehouseSearcher{warehouse: w, accounts: []string{server.URL}}
+
+	testCases := []struct {
+		query string
+		ids   []int
+		err   string
+	}{
+		{query: "type:ticket tags:beta", ids: []int{1, 4}},
+		{query: "type:ticket status<solved", ids: []int{1, 4}},
+		{query: "type:ticket group:support", ids: []int{1}},
+		{query: "type:ticket solved>=2017-01-04 assignee:Ann", ids: []int{2}},
+		{query: "type:ticket created>2017-02-01"},
+		{query: "type:ticket organization:acme", err: "The warehouse can't search by 'organization', use the search source for this report"},
+	}
+
+	for i, tc := range testCases {
+		tm, err := ws.TicketMetrics(&Query{Params: tc.query})
+
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("[spec %d] Expected error %q but got %v", i, tc.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("[spec %d] Unexpected error got %s", i, err)
+			continue
+		}
+
+		var ids []int
+		for _, ticket := range tm.Tickets {
+			ids = append(ids, ticket.ID)
+		}
+
+		if !reflect.DeepEqual(ids, tc.ids) || tm.Count != len(tc.ids) {
+			t.Errorf("[spec %d] Expected %q to find tickets %v but got %v", i, tc.query, tc.ids, ids)
+		}
+	}
+
+	ws.accounts = append(ws.accounts, "brand")
+	_, err = ws.SearchTickets(&Query{Params: "type:ticket"})
+	expectedErr := "Zendesk account 'brand' hasn't been synced to the warehouse, run the sync command first"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error %q but got %v", expectedErr, err)
+	}
+}
+
+func TestSyncWarehouseWithoutPath(t *testing.T) {
+	err := SyncWarehouse(&conf.Config{}, nil, nil)
+
+	expected := "The zendesk warehouse has no path to keep the tickets in"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q but got %v", expected, err)
+	}
+}
@@ -0,0 +1,240 @@
+package zendesktest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Update adds the tickets to those served, replacing any with the same ID,
+// such as to change tickets between incremental exports.
+func (z *Zendesk) Update(tickets ...Ticket) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for _, t := range tickets {
+		replaced := false
+
+		for i := range z.tickets {
+			if z.tickets[i].ID == t.ID {
+				z.tickets[i], replaced = t, true
+				break
+			}
+		}
+
+		if !replaced {
+			z.tickets = append(z.tickets, t)
+		}
+	}
+}
+
+// exportPosition is where a cursor points in the export, which is in the
+// order the tickets were last updated.
+type exportPosition struct {
+	updatedAt int64
+	id        int
+}
+
+func (p exportPosition) String() string {
+	return fmt.Sprintf("%d.%d", p.updatedAt, p.id)
+}
+
+func (p exportPosition) before(t *Ticket) bool {
+	u := t.UpdatedAt.Unix()
+	return u > p.updatedAt || (u == p.updatedAt && t.ID > p.id)
+}
+
+func parseCursor(cursor string) (exportPosition, error) {
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return exportPosition{}, fmt.Errorf("The cursor '%s' is not valid", cursor)
+	}
+
+	updatedAt, err1 := strconv.ParseInt(parts[0], 10, 64)
+	id, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return exportPosition{}, fmt.Errorf("The cursor '%s' is not valid", cursor)
+	}
+
+	return exportPosition{updatedAt: updatedAt, id: id}, nil
+}
+
+// export responds with a page of the incremental ticket export, the
+// tickets updated since the start_time or after the cursor in the order
+// they were updated, along with the sideloads asked for.
+func (z *Zendesk) export(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	var pos exportPosition
+	switch {
+	case params.Get("cursor") != "":
+		var err error
+		if pos, err = parseCursor(params.Get("cursor")); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case params.Get("start_time") != "":
+		start, err := strconv.ParseInt(params.Get("start_time"), 10, 64)
+		if err != nil || start < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("The start_time '%s' is not valid", params.Get("start_time")))
+			return
+		}
+
+		// Tickets updated at the start time are included.
+		pos = exportPosition{updatedAt: start - 1, id: int(^uint(0) >> 1)}
+	default:
+		writeError(w, http.StatusBadRequest, "The incremental export needs a start_time or cursor")
+		return
+	}
+
+	var updated []Ticket
+	for _, t := range z.tickets {
+		if pos.before(&t) {
+			updated = append(updated, t)
+		}
+	}
+
+	sort.SliceStable(updated, func(i, j int) bool {
+		if !updated[i].UpdatedAt.Equal(updated[j].UpdatedAt) {
+			return updated[i].UpdatedAt.Before(updated[j].UpdatedAt)
+		}
+
+		return updated[i].ID < updated[j].ID
+	})
+
+	size := z.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+
+	end := len(updated) <= size
+	if !end {
+		updated = updated[:size]
+	}
+
+	if len(updated) > 0 {
+		last := updated[len(updated)-1]
+		pos = exportPosition{updatedAt: last.UpdatedAt.Unix(), id: last.ID}
+	}
+
+	includes := map[string]bool{}
+	for _, inc := range strings.Split(params.Get("include"), ",") {
+		includes[inc] = true
+	}
+
+	tickets := []exportTicketJSON{}
+	metricSets := []metricSetJSON{}
+	users, groups := z.namesByID(func(t *Ticket) string { return t.Assignee }), z.namesByID(func(t *Ticket) string { return t.Group })
+
+	for _, t := range updated {
+		tickets = append(tickets, exportTicketJSON{
+			ID:         t.ID,
+			Subject:    t.Subject,
+			Type:       t.Type,
+			Status:     t.Status,
+			Priority:   t.Priority,
+			GroupID:    groups.id(t.Group),
+			AssigneeID: users.id(t.Assignee),
+			Tags:       t.Tags,
+			CreatedAt:  t.CreatedAt,
+			UpdatedAt:  t.UpdatedAt,
+			DueAt:      t.DueAt,
+		})
+
+		metricSets = append(metricSets, metricSetJSON{TicketID: t.ID, SolvedAt: t.SolvedAt, MetricSet: t.Metrics})
+	}
+
+	body := map[string]interface{}{
+		"tickets":       tickets,
+		"after_cursor":  pos.String(),
+		"end_of_stream": end,
+	}
+
+	if includes["metric_sets"] {
+		body["metric_sets"] = metricSets
+	}
+
+	if includes["users"] {
+		body["users"] = users.sideload(updated, func(t *Ticket) string { return t.Assignee })
+	}
+
+	if includes["groups"] {
+		body["groups"] = groups.sideload(updated, func(t *Ticket) string { return t.Group })
+	}
+
+	writeJSON(w, body)
+}
+
+// exportTicketJSON is a ticket as the incremental export returns it, with
+// the IDs of its group and assignee in place of their names.
+type exportTicketJSON struct {
+	ID         int        `json:"id"`
+	Subject    string     `json:"subject"`
+	Type       string     `json:"type"`
+	Status     string     `json:"status"`
+	Priority   string     `json:"priority"`
+	GroupID    *int       `json:"group_id"`
+	AssigneeID *int       `json:"assignee_id"`
+	Tags       []string   `json:"tags"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DueAt      *time.Time `json:"due_at"`
+}
+
+// metricSetJSON is a sideloaded metric set, which names its ticket.
+type metricSetJSON struct {
+	TicketID int        `json:"ticket_id"`
+	SolvedAt *time.Time `json:"solved_at"`
+	MetricSet
+}
+
+type namedJSON struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// names numbers the group or assignee names of the tickets, in order, as
+// the fake only knows them by name.
+type names []string
+
+func (z *Zendesk) namesByID(name func(*Ticket) string) names {
+	var ns names
+	for i := range z.tickets {
+		if n := name(&z.tickets[i]); n != "" && ns.id(n) == nil {
+			ns = append(ns, n)
+		}
+	}
+
+	sort.Strings(ns)
+	return ns
+}
+
+func (ns names) id(name string) *int {
+	for i, n := range ns {
+		if n == name {
+			id := i + 1
+			return &id
+		}
+	}
+
+	return nil
+}
+
+// sideload returns the named groups or users of the tickets.
+func (ns names) sideload(tickets []Ticket, name func(*Ticket) string) []namedJSON {
+	sideloaded := []namedJSON{}
+	seen := map[string]bool{}
+
+	for i := range tickets {
+		n := name(&tickets[i])
+		if id := ns.id(n); id != nil && !seen[n] {
+			seen[n] = true
+			sideloaded = append(sideloaded, namedJSON{ID: *id, Name: n})
+		}
+	}
+
+	return sideloaded
+}
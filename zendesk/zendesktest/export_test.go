@@ -0,0 +1,114 @@
+package zendesktest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIncrementalExport(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 1, d, 9, 0, 0, 0, time.UTC) }
+
+	z := New([]Ticket{
+		{ID: 1, Status: "open", Group: "Support", UpdatedAt: day(3)},
+		{ID: 2, Status: "solved", Assignee: "Ann", UpdatedAt: day(1), SolvedAt: timePtr(day(1))},
+		{ID: 3, Status: "pending", Group: "Billing", UpdatedAt: day(2)},
+	})
+	z.PageSize = 2
+
+	server := httptest.NewServer(z)
+	defer server.Close()
+
+	type page struct {
+		Tickets []struct {
+			ID         int  `json:"id"`
+			GroupID    *int `json:"group_id"`
+			AssigneeID *int `json:"assignee_id"`
+		} `json:"tickets"`
+		MetricSets []struct {
+			TicketID int        `json:"ticket_id"`
+			SolvedAt *time.Time `json:"solved_at"`
+		} `json:"metric_sets"`
+		Groups      []namedJSON `json:"groups"`
+		Users       []namedJSON `json:"users"`
+		AfterCursor string      `json:"after_cursor"`
+		EndOfStream bool        `json:"end_of_stream"`
+	}
+
+	export := func(params url.Values) page {
+		params.Set("include", "metric_sets,users,groups")
+
+		status, body := get(t, server.URL+"/api/v2/incremental/tickets/cursor.json?"+params.Encode())
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200 but got %d: %s", status, body)
+		}
+
+		var p page
+		if err := json.Unmarshal([]byte(body), &p); err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	ids := func(p page) []int {
+		var ids []int
+		for _, t := range p.Tickets {
+			ids = append(ids, t.ID)
+		}
+
+		return ids
+	}
+
+	first := export(url.Values{"start_time": {"0"}})
+	if !reflect.DeepEqual(ids(first), []int{2, 3}) || first.EndOfStream {
+		t.Errorf("Expected the first page to have tickets [2 3] and more to come but got %v %t", ids(first), first.EndOfStream)
+	}
+
+	if len(first.MetricSets) != 2 || first.MetricSets[0].TicketID != 2 || first.MetricSets[0].SolvedAt == nil {
+		t.Errorf("Expected the metric sets of the tickets to be sideloaded but got %+v", first.MetricSets)
+	}
+
+	if !reflect.DeepEqual(first.Users, []namedJSON{{ID: 1, Name: "Ann"}}) ||
+		!reflect.DeepEqual(first.Groups, []namedJSON{{ID: 1, Name: "Billing"}}) {
+		t.Errorf("Expected the users and groups of the tickets to be sideloaded but got %+v %+v", first.Users, first.Groups)
+	}
+
+	if g := first.Tickets[1].GroupID; g == nil || *g != 1 || first.Tickets[0].GroupID != nil {
+		t.Errorf("Expected the tickets to have their group IDs but got %+v", first.Tickets)
+	}
+
+	second := export(url.Values{"cursor": {first.AfterCursor}})
+	if !reflect.DeepEqual(ids(second), []int{1}) || !second.EndOfStream {
+		t.Errorf("Expected the last page to have ticket [1] but got %v %t", ids(second), second.EndOfStream)
+	}
+
+	// Resuming from the end only returns tickets updated since.
+	if p := export(url.Values{"cursor": {second.AfterCursor}}); len(p.Tickets) != 0 || !p.EndOfStream || p.AfterCursor != second.AfterCursor {
+		t.Errorf("Expected no more tickets but got %v", ids(p))
+	}
+
+	z.Update(Ticket{ID: 2, Status: "closed", UpdatedAt: day(4)}, Ticket{ID: 4, Status: "new", UpdatedAt: day(5)})
+
+	if p := export(url.Values{"cursor": {second.AfterCursor}}); !reflect.DeepEqual(ids(p), []int{2, 4}) {
+		t.Errorf("Expected the updated tickets [2 4] but got %v", ids(p))
+	}
+
+	start := strconv.FormatInt(day(2).Unix(), 10)
+	if p := export(url.Values{"start_time": {start}}); !reflect.DeepEqual(ids(p), []int{3, 1}) {
+		t.Errorf("Expected the tickets updated from the start time [3 1] but got %v", ids(p))
+	}
+
+	if status, _ := get(t, server.URL+"/api/v2/incremental/tickets/cursor.json?cursor=nope"); status != http.StatusBadRequest {
+		t.Errorf("Expected an invalid cursor to be rejected but got status %d", status)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
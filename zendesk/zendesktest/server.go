@@ -0,0 +1,268 @@
+package zendesktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/geckoboard/zendesk_dataset/zendesk/search"
+)
+
+// DefaultPageSize is the number of search results in each page, the same
+// as Zendesk's.
+const DefaultPageSize = 100
+
+// Zendesk is a fake Zendesk API serving the searches, tickets with their
+// metric sets, the incremental ticket export and the authenticated user
+// from its tickets. It accepts any credentials but requires some to be
+// sent. Use it as the handler of an httptest.Server or any other
+// http.Server.
+type Zendesk struct {
+	// PageSize is the number of search results in each page, which
+	// defaults to DefaultPageSize.
+	PageSize int
+
+	mu       sync.Mutex
+	tickets  []Ticket
+	requests []string
+	failures []failure
+}
+
+// failure is a response sent in place of the next request's.
+type failure struct {
+	status     int
+	message    string
+	retryAfter int
+}
+
+// New returns a fake Zendesk serving the tickets.
+func New(tickets []Ticket) *Zendesk {
+	return &Zendesk{tickets: tickets}
+}
+
+// Fail makes the next n requests fail with the status and message.
+func (z *Zendesk) Fail(n, status int, message string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		z.failures = append(z.failures, failure{status: status, message: message})
+	}
+}
+
+// RateLimit makes the next n requests fail as rate limited, asking to
+// retry after the number of seconds.
+func (z *Zendesk) RateLimit(n, retryAfter int) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		z.failures = append(z.failures, failure{
+			status:     http.StatusTooManyRequests,
+			message:    "Number of allowed API requests per minute exceeded",
+			retryAfter: retryAfter,
+		})
+	}
+}
+
+// Requests returns the path and query of each request received, in order.
+func (z *Zendesk) Requests() []string {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	return append([]string(nil), z.requests...)
+}
+
+func (z *Zendesk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	z.requests = append(z.requests, r.URL.RequestURI())
+
+	if len(z.failures) > 0 {
+		f := z.failures[0]
+		z.failures = z.failures[1:]
+
+		if f.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(f.retryAfter))
+		}
+
+		writeError(w, f.status, f.message)
+		return
+	}
+
+	if r.Header.Get("Authorization") == "" {
+		writeError(w, http.StatusUnauthorized, "Couldn't authenticate you")
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("The fake Zendesk doesn't support %s requests", r.Method))
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/v2/search.json":
+		z.search(w, r)
+	case "/api/v2/tickets/show_many.json":
+		z.showMany(w, r)
+	case "/api/v2/incremental/tickets/cursor.json":
+		z.export(w, r)
+	case "/api/v2/users/me.json":
+		writeJSON(w, map[string]interface{}{
+			"user": map[string]interface{}{"id": 1, "name": "Fake Zendesk user"},
+		})
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The fake Zendesk doesn't serve %s", r.URL.Path))
+	}
+}
+
+// search responds with a page of the tickets matching the query, with
+// the next_page URL when there are more.
+func (z *Zendesk) search(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	q, err := search.Parse(params.Get("query"))
+	if e, ok := err.(search.UnsupportedKeyError); ok {
+		err = fmt.Errorf("The fake Zendesk can't search by '%s'", e.Key)
+	}
+
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var matched []Ticket
+	for i := range z.tickets {
+		if q.Matches(z.tickets[i].searchable()) {
+			matched = append(matched, z.tickets[i])
+		}
+	}
+
+	page := 1
+	if p := params.Get("page"); p != "" {
+		if page, err = strconv.Atoi(p); err != nil || page < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("The page '%s' is not valid", p))
+			return
+		}
+	}
+
+	size := z.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+
+	start, end := (page-1)*size, page*size
+	if start > len(matched) {
+		start = len(matched)
+	}
+
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	body := struct {
+		Results  []ticketJSON `json:"results"`
+		Count    int          `json:"count"`
+		NextPage *string      `json:"next_page"`
+	}{
+		Results: []ticketJSON{},
+		Count:   len(matched),
+	}
+
+	for _, t := range matched[start:end] {
+		body.Results = append(body.Results, newTicketJSON(t, false))
+	}
+
+	if end < len(matched) {
+		next := nextPageURL(r, page+1)
+		body.NextPage = &next
+	}
+
+	writeJSON(w, body)
+}
+
+// showMany responds with the tickets with the ids, along with their metric
+// sets when they are included.
+func (z *Zendesk) showMany(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	withMetrics := false
+
+	for _, inc := range strings.Split(params.Get("include"), ",") {
+		withMetrics = withMetrics || inc == "metric_sets"
+	}
+
+	body := struct {
+		Tickets []ticketJSON `json:"tickets"`
+		Count   int          `json:"count"`
+	}{
+		Tickets: []ticketJSON{},
+	}
+
+	for _, s := range strings.Split(params.Get("ids"), ",") {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("The ticket id '%s' is not valid", s))
+			return
+		}
+
+		for _, t := range z.tickets {
+			if t.ID == id {
+				body.Tickets = append(body.Tickets, newTicketJSON(t, withMetrics))
+			}
+		}
+	}
+
+	body.Count = len(body.Tickets)
+	writeJSON(w, body)
+}
+
+// ticketJSON is a ticket as the API returns it, only having the metric set
+// when it is sideloaded.
+type ticketJSON struct {
+	Ticket
+	Metrics *MetricSet `json:"metric_set,omitempty"`
+}
+
+func newTicketJSON(t Ticket, withMetrics bool) ticketJSON {
+	tj := ticketJSON{Ticket: t}
+	if withMetrics {
+		tj.Metrics = &t.Metrics
+	}
+
+	return tj
+}
+
+func nextPageURL(r *http.Request, page int) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	params := r.URL.Query()
+	params.Set("page", strconv.Itoa(page))
+
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: params.Encode()}
+	return u.String()
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError responds with the status and an error described as Zendesk
+// does, which the client reads the message from.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":       http.StatusText(status),
+		"description": message,
+	})
+}
@@ -0,0 +1,173 @@
+package zendesktest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetBasicAuth("test@example.com/token", "12345")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, strings.TrimSpace(string(b))
+}
+
+func TestSearchPagination(t *testing.T) {
+	z := New([]Ticket{
+		{ID: 1, Status: "open"},
+		{ID: 2, Status: "solved"},
+		{ID: 3, Status: "open"},
+		{ID: 4, Status: "open"},
+	})
+	z.PageSize = 2
+
+	server := httptest.NewServer(z)
+	defer server.Close()
+
+	var ids []int
+	next := server.URL + "/api/v2/search.json?query=type%3Aticket+status%3Aopen"
+
+	for next != "" {
+		status, body := get(t, next)
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200 but got %d: %s", status, body)
+		}
+
+		var page struct {
+			Results []struct {
+				ID        int             `json:"id"`
+				MetricSet json.RawMessage `json:"metric_set"`
+			} `json:"results"`
+			Count    int    `json:"count"`
+			NextPage string `json:"next_page"`
+		}
+
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatal(err)
+		}
+
+		if page.Count != 3 {
+			t.Errorf("Expected count 3 but got %d", page.Count)
+		}
+
+		for _, r := range page.Results {
+			if r.MetricSet != nil {
+				t.Errorf("Expected search results without metric sets but got %s", r.MetricSet)
+			}
+
+			ids = append(ids, r.ID)
+		}
+
+		next = page.NextPage
+	}
+
+	if !reflect.DeepEqual(ids, []int{1, 3, 4}) {
+		t.Errorf("Expected tickets [1 3 4] but got %v", ids)
+	}
+
+	expected := []string{
+		"/api/v2/search.json?query=type%3Aticket+status%3Aopen",
+		"/api/v2/search.json?page=2&query=type%3Aticket+status%3Aopen",
+	}
+
+	if !reflect.DeepEqual(z.Requests(), expected) {
+		t.Errorf("Expected requests %v but got %v", expected, z.Requests())
+	}
+}
+
+func TestShowManyAndErrors(t *testing.T) {
+	z := New([]Ticket{
+		{ID: 1, Metrics: MetricSet{ReplyTime: Metric{Business: 10, Calendar: 20}}},
+		{ID: 2},
+	})
+
+	server := httptest.NewServer(z)
+	defer server.Close()
+
+	testCases := []struct {
+		setup  func()
+		path   string
+		status int
+		body   string
+	}{
+		{
+			path:   "/api/v2/tickets/show_many.json?ids=1&include=metric_sets",
+			status: http.StatusOK,
+			body:   `"metric_set":{"reply_time_in_minutes":{"business":10,"calendar":20}`,
+		},
+		{
+			path:   "/api/v2/users/me.json",
+			status: http.StatusOK,
+			body:   `{"user":{"id":1,"name":"Fake Zendesk user"}}`,
+		},
+		{
+			path:   "/api/v2/search.json?query=brand%3Aone",
+			status: http.StatusUnprocessableEntity,
+			body:   `"description":"The fake Zendesk can't search by 'brand'"`,
+		},
+		{
+			setup:  func() { z.RateLimit(1, 30) },
+			path:   "/api/v2/users/me.json",
+			status: http.StatusTooManyRequests,
+			body:   `"description":"Number of allowed API requests per minute exceeded"`,
+		},
+		{
+			setup:  func() { z.Fail(1, http.StatusInternalServerError, "Something went wrong") },
+			path:   "/api/v2/users/me.json",
+			status: http.StatusInternalServerError,
+			body:   `{"description":"Something went wrong","error":"Internal Server Error"}`,
+		},
+		{
+			path:   "/api/v2/users.json",
+			status: http.StatusNotFound,
+			body:   `"description":"The fake Zendesk doesn't serve /api/v2/users.json"`,
+		},
+	}
+
+	for i, tc := range testCases {
+		if tc.setup != nil {
+			tc.setup()
+		}
+
+		status, body := get(t, server.URL+tc.path)
+
+		if status != tc.status {
+			t.Errorf("[spec %d] Expected status %d but got %d", i, tc.status, status)
+		}
+
+		if !strings.Contains(body, tc.body) {
+			t.Errorf("[spec %d] Expected the body to contain %s but got %s", i, tc.body, body)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/api/v2/users/me.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials but got %d", resp.StatusCode)
+	}
+}
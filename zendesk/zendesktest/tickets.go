@@ -0,0 +1,126 @@
+// Package zendesktest provides a fake Zendesk API serving a set of tickets,
+// so reports can be tried and tested without a Zendesk account.
+package zendesktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/geckoboard/zendesk_dataset/zendesk/search"
+)
+
+// Ticket is a ticket served by the fake Zendesk, with the attributes its
+// searches can filter on.
+type Ticket struct {
+	ID       int      `json:"id"`
+	Subject  string   `json:"subject"`
+	Type     string   `json:"type"`
+	Status   string   `json:"status"`
+	Priority string   `json:"priority"`
+	Group    string   `json:"group"`
+	Assignee string   `json:"assignee"`
+	Tags     []string `json:"tags"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	SolvedAt  *time.Time `json:"solved_at,omitempty"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+
+	Metrics MetricSet `json:"metric_set"`
+}
+
+// searchable returns the ticket's attributes to search.
+func (t *Ticket) searchable() *search.Ticket {
+	return &search.Ticket{
+		Type:      t.Type,
+		Status:    t.Status,
+		Priority:  t.Priority,
+		Group:     t.Group,
+		Assignee:  t.Assignee,
+		Tags:      t.Tags,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		SolvedAt:  t.SolvedAt,
+		DueAt:     t.DueAt,
+	}
+}
+
+// MetricSet holds the ticket's metrics returned when the metric sets are
+// sideloaded.
+type MetricSet struct {
+	ReplyTime           Metric `json:"reply_time_in_minutes"`
+	FirstResolutionTime Metric `json:"first_resolution_time_in_minutes"`
+	FullResolutionTime  Metric `json:"full_resolution_time_in_minutes"`
+	AgentWaitTime       Metric `json:"agent_wait_time_in_minutes"`
+	RequesterWaitTime   Metric `json:"requester_wait_time_in_minutes"`
+	OnHoldTime          Metric `json:"on_hold_time_in_minutes"`
+}
+
+// Metric is a time in minutes within business hours and in calendar time.
+type Metric struct {
+	Business int `json:"business"`
+	Calendar int `json:"calendar"`
+}
+
+// ReadTickets reads the tickets from a JSON file holding them under
+// "tickets", as the Zendesk tickets endpoints return them.
+func ReadTickets(path string) ([]Ticket, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Tickets []Ticket `json:"tickets"`
+	}
+
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, fmt.Errorf("Tickets file %s is not valid: %s", path, err.Error())
+	}
+
+	return body.Tickets, nil
+}
+
+// ShiftDates moves the dates of all the tickets by the same amount so the
+// most recently created ticket was created at now. This keeps fixtures
+// matching reports which search relative to today, such as the past 30 days.
+func ShiftDates(tickets []Ticket, now time.Time) {
+	var latest time.Time
+	for _, t := range tickets {
+		if t.CreatedAt.After(latest) {
+			latest = t.CreatedAt
+		}
+	}
+
+	if latest.IsZero() {
+		return
+	}
+
+	d := now.Sub(latest)
+	shift := func(t *time.Time) {
+		if t != nil && !t.IsZero() {
+			*t = t.Add(d)
+		}
+	}
+
+	for i := range tickets {
+		t := &tickets[i]
+		shift(&t.CreatedAt)
+		shift(&t.UpdatedAt)
+
+		// Copy the optional dates so tickets sharing them aren't moved twice.
+		if t.SolvedAt != nil {
+			solved := *t.SolvedAt
+			shift(&solved)
+			t.SolvedAt = &solved
+		}
+
+		if t.DueAt != nil {
+			due := *t.DueAt
+			shift(&due)
+			t.DueAt = &due
+		}
+	}
+}
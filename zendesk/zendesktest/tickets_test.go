@@ -0,0 +1,56 @@
+package zendesktest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTickets(t *testing.T) {
+	tickets, err := ReadTickets(filepath.Join("..", "..", "fixtures", "zendesk_tickets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tickets) != 24 {
+		t.Errorf("Expected 24 tickets but got %d", len(tickets))
+	}
+
+	if tickets[0].ID != 1 || tickets[0].CreatedAt.IsZero() || tickets[0].Metrics.ReplyTime.Calendar == 0 {
+		t.Errorf("Expected the first ticket to have an id, created date and metrics but got %+v", tickets[0])
+	}
+
+	_, err = ReadTickets(filepath.Join("..", "..", "fixtures", "example.yml"))
+	if err == nil || !strings.HasPrefix(err.Error(), "Tickets file "+filepath.Join("..", "..", "fixtures", "example.yml")+" is not valid") {
+		t.Errorf("Expected an invalid tickets file error but got %v", err)
+	}
+}
+
+func TestShiftDates(t *testing.T) {
+	solved := time.Date(2017, 1, 12, 0, 0, 0, 0, time.UTC)
+	tickets := []Ticket{
+		{CreatedAt: time.Date(2017, 1, 10, 0, 0, 0, 0, time.UTC), SolvedAt: &solved},
+		{CreatedAt: time.Date(2017, 1, 15, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2017, 1, 16, 0, 0, 0, 0, time.UTC)},
+	}
+
+	ShiftDates(tickets, time.Date(2017, 2, 15, 0, 0, 0, 0, time.UTC))
+
+	expected := []time.Time{
+		time.Date(2017, 2, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 2, 12, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 2, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 2, 16, 0, 0, 0, 0, time.UTC),
+	}
+
+	actual := []time.Time{tickets[0].CreatedAt, *tickets[0].SolvedAt, tickets[1].CreatedAt, tickets[1].UpdatedAt}
+	for i := range expected {
+		if !actual[i].Equal(expected[i]) {
+			t.Errorf("[spec %d] Expected %s but got %s", i, expected[i], actual[i])
+		}
+	}
+
+	if !tickets[0].UpdatedAt.IsZero() || !solved.Equal(time.Date(2017, 1, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected unset and shared dates to be left as they were")
+	}
+}